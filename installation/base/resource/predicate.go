@@ -1,6 +1,13 @@
 package resource
 
-import "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+import (
+	"path"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
 
 // Predicate defines a function filter out resources disabled for certain operations
 type Predicate func(unstructured.Unstructured) bool
@@ -30,6 +37,13 @@ func OrPredicates(predicates ...Predicate) Predicate {
 	}
 }
 
+// Not negates the given predicate
+func Not(predicate Predicate) Predicate {
+	return func(u unstructured.Unstructured) bool {
+		return !predicate(u)
+	}
+}
+
 // SplitByPredicates splits a list of unstructured objects into two lists
 // one that matches all the given predicates and one that does not
 func SplitByPredicates(objs []unstructured.Unstructured, predicates Predicate) ([]unstructured.Unstructured, []unstructured.Unstructured) {
@@ -83,3 +97,113 @@ func hasElemInMap(m map[string]string, key, value string) bool {
 	v, exists := m[key]
 	return exists && v == value
 }
+
+// HasGroupKind returns true for resources of the kind from the API group, e.g. "apps" and "Deployment"
+// the core group is an empty string
+func HasGroupKind(group, kind string) Predicate {
+	return func(u unstructured.Unstructured) bool {
+		gvk := u.GroupVersionKind()
+		return gvk.Group == group && gvk.Kind == kind
+	}
+}
+
+// HasAPIVersion returns true for resources with the apiVersion, e.g. "apps/v1"
+func HasAPIVersion(apiVersion string) Predicate {
+	return func(u unstructured.Unstructured) bool {
+		return u.GetAPIVersion() == apiVersion
+	}
+}
+
+// InNamespace returns true for resources from any of the given namespaces
+func InNamespace(namespaces ...string) Predicate {
+	return func(u unstructured.Unstructured) bool {
+		for _, ns := range namespaces {
+			if u.GetNamespace() == ns {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasName returns true for resources with exactly the given name
+func HasName(name string) Predicate {
+	return func(u unstructured.Unstructured) bool {
+		return u.GetName() == name
+	}
+}
+
+// HasNameMatching returns true for resources with names matching the glob pattern, e.g. "*-webhook"
+// invalid patterns don't match any resource
+func HasNameMatching(pattern string) Predicate {
+	return func(u unstructured.Unstructured) bool {
+		matched, err := path.Match(pattern, u.GetName())
+		return err == nil && matched
+	}
+}
+
+// HasLabelKey returns true for resources with the label regardless of its value
+func HasLabelKey(key string) Predicate {
+	return func(u unstructured.Unstructured) bool {
+		_, exists := u.GetLabels()[key]
+		return exists
+	}
+}
+
+// HasAnnotationKey returns true for resources with the annotation regardless of its value
+func HasAnnotationKey(key string) Predicate {
+	return func(u unstructured.Unstructured) bool {
+		_, exists := u.GetAnnotations()[key]
+		return exists
+	}
+}
+
+// MatchesLabelSelector parses the label selector string, e.g. "app=test,tier notin (db)"
+func MatchesLabelSelector(selector string) (Predicate, error) {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return matchesSelector(parsed), nil
+}
+
+// MatchesMetaLabelSelector converts the label selector used in Kubernetes APIs to the predicate
+func MatchesMetaLabelSelector(selector *metav1.LabelSelector) (Predicate, error) {
+	parsed, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return matchesSelector(parsed), nil
+}
+
+func matchesSelector(selector labels.Selector) Predicate {
+	return func(u unstructured.Unstructured) bool {
+		return selector.Matches(labels.Set(u.GetLabels()))
+	}
+}
+
+// IsClusterScoped returns true for resources without namespace scope according to the RESTMapper
+// resources unknown to the mapper are not considered cluster-scoped
+func IsClusterScoped(mapper meta.RESTMapper) Predicate {
+	return func(u unstructured.Unstructured) bool {
+		gvk := u.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return false
+		}
+
+		return mapping.Scope.Name() == meta.RESTScopeNameRoot
+	}
+}
+
+// IsWorkload returns true for resources running pods
+func IsWorkload(u unstructured.Unstructured) bool {
+	switch u.GroupVersionKind().GroupKind().String() {
+	case "Deployment.apps", "StatefulSet.apps", "DaemonSet.apps", "ReplicaSet.apps", "Job.batch", "CronJob.batch", "Pod":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,201 @@
+package resource
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var setRequirementRegexp = regexp.MustCompile(`^([^\s"']+)\s+(in|notin)\s+\((.*)\)$`)
+
+// ParsePredicate builds the predicate from the expression so it can be configured in YAML files.
+// Requirements separated by commas must be all met and alternatives separated by semicolons are ORed, e.g.
+//
+//	kind=Deployment,ns in (a,b);kind=CustomResourceDefinition,name=*.kyma-project.io
+//
+// Supported keys are kind, group, apiVersion, namespace (ns), name (glob), label.<key> and annotation.<key>
+// with operators =, ==, !=, in and notin. Values can be quoted with " or ' to contain commas, semicolons or parentheses.
+// Bare keys check presence of the label or annotation, and the workload and crd words match workloads and CRDs.
+// Each requirement can be negated with the "!" prefix.
+func ParsePredicate(expr string) (Predicate, error) {
+	alternatives := []Predicate{}
+	for _, alternative := range splitExpression(expr, ';') {
+		requirements := []Predicate{}
+		for _, requirement := range splitRequirements(alternative) {
+			predicate, err := parseRequirement(requirement)
+			if err != nil {
+				return nil, fmt.Errorf("invalid predicate '%s': %s", expr, err.Error())
+			}
+			requirements = append(requirements, predicate)
+		}
+
+		if len(requirements) == 0 {
+			return nil, fmt.Errorf("invalid predicate '%s': empty expression", expr)
+		}
+		alternatives = append(alternatives, AndPredicates(requirements...))
+	}
+
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return OrPredicates(alternatives...), nil
+}
+
+// splitRequirements splits the expression by commas which are not inside parentheses or quotes
+func splitRequirements(expr string) []string {
+	requirements := []string{}
+	for _, requirement := range splitExpression(expr, ',') {
+		requirements = appendRequirement(requirements, requirement)
+	}
+
+	return requirements
+}
+
+// splitExpression splits the expression by the separator which is not inside parentheses or quotes
+func splitExpression(expr string, separator rune) []string {
+	parts := []string{}
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == separator && depth == 0:
+			parts = append(parts, expr[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, expr[start:])
+}
+
+// unquote trims spaces and quotes around the value
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func appendRequirement(requirements []string, requirement string) []string {
+	requirement = strings.TrimSpace(requirement)
+	if requirement == "" {
+		return requirements
+	}
+	return append(requirements, requirement)
+}
+
+func parseRequirement(requirement string) (Predicate, error) {
+	if strings.HasPrefix(requirement, "!") && !strings.HasPrefix(requirement, "!=") {
+		predicate, err := parseRequirement(strings.TrimSpace(requirement[1:]))
+		if err != nil {
+			return nil, err
+		}
+		return Not(predicate), nil
+	}
+
+	if match := setRequirementRegexp.FindStringSubmatch(requirement); match != nil {
+		values := []string{}
+		for _, value := range splitExpression(match[3], ',') {
+			values = append(values, unquote(value))
+		}
+
+		predicate, err := valuesPredicate(match[1], values)
+		if err != nil {
+			return nil, err
+		}
+		if match[2] == "notin" {
+			return Not(predicate), nil
+		}
+		return predicate, nil
+	}
+
+	// operators are searched only before the quoted value
+	keyPart := requirement
+	if i := strings.IndexAny(requirement, `"'`); i >= 0 {
+		keyPart = requirement[:i]
+	}
+	for _, operator := range []string{"!=", "==", "="} {
+		i := strings.Index(keyPart, operator)
+		if i < 0 {
+			continue
+		}
+
+		key, value := requirement[:i], requirement[i+len(operator):]
+		predicate, err := valuesPredicate(strings.TrimSpace(key), []string{unquote(value)})
+		if err != nil {
+			return nil, err
+		}
+		if operator == "!=" {
+			return Not(predicate), nil
+		}
+		return predicate, nil
+	}
+
+	return existencePredicate(requirement)
+}
+
+// valuesPredicate returns the predicate matching resources with the key equal to any of the values
+func valuesPredicate(key string, values []string) (Predicate, error) {
+	var valueFunc func(value string) Predicate
+	switch {
+	case key == "kind":
+		valueFunc = HasKind
+	case key == "group":
+		valueFunc = func(value string) Predicate {
+			return func(u unstructured.Unstructured) bool {
+				return u.GroupVersionKind().Group == value
+			}
+		}
+	case key == "apiVersion":
+		valueFunc = HasAPIVersion
+	case key == "namespace" || key == "ns":
+		valueFunc = func(value string) Predicate {
+			return InNamespace(value)
+		}
+	case key == "name":
+		valueFunc = HasNameMatching
+	case strings.HasPrefix(key, "label.") && len(key) > len("label."):
+		valueFunc = func(value string) Predicate {
+			return HasLabel(strings.TrimPrefix(key, "label."), value)
+		}
+	case strings.HasPrefix(key, "annotation.") && len(key) > len("annotation."):
+		valueFunc = func(value string) Predicate {
+			return HasAnnotation(strings.TrimPrefix(key, "annotation."), value)
+		}
+	default:
+		return nil, fmt.Errorf("unknown key '%s'", key)
+	}
+
+	predicates := make([]Predicate, 0, len(values))
+	for _, value := range values {
+		predicates = append(predicates, valueFunc(value))
+	}
+	return OrPredicates(predicates...), nil
+}
+
+func existencePredicate(word string) (Predicate, error) {
+	switch {
+	case word == "workload":
+		return IsWorkload, nil
+	case word == "crd":
+		return IsCRD, nil
+	case strings.HasPrefix(word, "label.") && len(word) > len("label."):
+		return HasLabelKey(strings.TrimPrefix(word, "label.")), nil
+	case strings.HasPrefix(word, "annotation.") && len(word) > len("annotation."):
+		return HasAnnotationKey(strings.TrimPrefix(word, "annotation.")), nil
+	default:
+		return nil, fmt.Errorf("unknown requirement '%s'", word)
+	}
+}
@@ -0,0 +1,228 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func fixObj(apiVersion, kind, namespace, name string, labels map[string]string) unstructured.Unstructured {
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	u.SetLabels(labels)
+	return u
+}
+
+var (
+	testDeployment = fixObj("apps/v1", "Deployment", "kyma-system", "test-manager", map[string]string{"app": "test", "tier": "backend"})
+	testWebhook    = fixObj("admissionregistration.k8s.io/v1", "ValidatingWebhookConfiguration", "", "test-webhook", nil)
+	testCRD        = fixObj("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "tests.operator.kyma-project.io", map[string]string{"app": "test"})
+	testService    = fixObj("v1", "Service", "default", "test-service", map[string]string{"tier": "frontend"})
+)
+
+func TestPredicates(t *testing.T) {
+	tests := []struct {
+		name      string
+		predicate Predicate
+		want      []unstructured.Unstructured
+	}{
+		{
+			name:      "not",
+			predicate: Not(IsCRD),
+			want:      []unstructured.Unstructured{testDeployment, testWebhook, testService},
+		},
+		{
+			name:      "has group kind",
+			predicate: HasGroupKind("apps", "Deployment"),
+			want:      []unstructured.Unstructured{testDeployment},
+		},
+		{
+			name:      "has api version",
+			predicate: HasAPIVersion("v1"),
+			want:      []unstructured.Unstructured{testService},
+		},
+		{
+			name:      "in namespace",
+			predicate: InNamespace("default", "kyma-system"),
+			want:      []unstructured.Unstructured{testDeployment, testService},
+		},
+		{
+			name:      "has name",
+			predicate: HasName("test-webhook"),
+			want:      []unstructured.Unstructured{testWebhook},
+		},
+		{
+			name:      "has name matching",
+			predicate: HasNameMatching("*.kyma-project.io"),
+			want:      []unstructured.Unstructured{testCRD},
+		},
+		{
+			name:      "has name matching invalid pattern",
+			predicate: HasNameMatching("[test"),
+			want:      []unstructured.Unstructured{},
+		},
+		{
+			name:      "has label key",
+			predicate: HasLabelKey("tier"),
+			want:      []unstructured.Unstructured{testDeployment, testService},
+		},
+		{
+			name:      "is workload",
+			predicate: IsWorkload,
+			want:      []unstructured.Unstructured{testDeployment},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, filter(tt.predicate))
+		})
+	}
+}
+
+func TestHasAnnotationKey(t *testing.T) {
+	annotated := testService.DeepCopy()
+	annotated.SetAnnotations(map[string]string{"test": ""})
+
+	require.True(t, HasAnnotationKey("test")(*annotated))
+	require.False(t, HasAnnotationKey("other")(*annotated))
+	require.False(t, HasAnnotationKey("test")(testService))
+}
+
+func TestMatchesLabelSelector(t *testing.T) {
+	t.Run("match selector", func(t *testing.T) {
+		predicate, err := MatchesLabelSelector("app=test,tier notin (frontend)")
+		require.NoError(t, err)
+		require.Equal(t, []unstructured.Unstructured{testDeployment, testCRD}, filter(predicate))
+	})
+
+	t.Run("invalid selector", func(t *testing.T) {
+		_, err := MatchesLabelSelector("app in test")
+		require.Error(t, err)
+	})
+
+	t.Run("match meta selector", func(t *testing.T) {
+		predicate, err := MatchesMetaLabelSelector(&metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "test"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "tier", Operator: metav1.LabelSelectorOpExists},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []unstructured.Unstructured{testDeployment}, filter(predicate))
+	})
+
+	t.Run("invalid meta selector", func(t *testing.T) {
+		_, err := MatchesMetaLabelSelector(&metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: "Unknown"}},
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestIsClusterScoped(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Service"}, meta.RESTScopeNamespace)
+
+	// webhook is unknown to the mapper
+	require.Equal(t, []unstructured.Unstructured{testCRD}, filter(IsClusterScoped(mapper)))
+}
+
+func TestParsePredicate(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    []unstructured.Unstructured
+		wantErr string
+	}{
+		{
+			name: "kind",
+			expr: "kind=Deployment",
+			want: []unstructured.Unstructured{testDeployment},
+		},
+		{
+			name: "requirements are combined",
+			expr: "kind in (Deployment, Service), ns in (default,kyma-system) , label.tier!=frontend",
+			want: []unstructured.Unstructured{testDeployment},
+		},
+		{
+			name: "alternatives",
+			expr: "group==apps;name=*.kyma-project.io",
+			want: []unstructured.Unstructured{testDeployment, testCRD},
+		},
+		{
+			name: "notin",
+			expr: "namespace notin (default)",
+			want: []unstructured.Unstructured{testDeployment, testWebhook, testCRD},
+		},
+		{
+			name: "api version",
+			expr: "apiVersion=admissionregistration.k8s.io/v1",
+			want: []unstructured.Unstructured{testWebhook},
+		},
+		{
+			name: "label presence and negation",
+			expr: "label.app,!crd",
+			want: []unstructured.Unstructured{testDeployment},
+		},
+		{
+			name: "workload",
+			expr: "!workload,!label.tier",
+			want: []unstructured.Unstructured{testWebhook, testCRD},
+		},
+		{
+			name: "annotation",
+			expr: "annotation.test=value;annotation.other",
+			want: []unstructured.Unstructured{},
+		},
+		{
+			name: "separators inside lists and quotes",
+			expr: `kind in (Deployment, Service),ns notin (a;b),label.tier notin ("front,end;");name='test;manager',label.tier!="a=b"`,
+			want: []unstructured.Unstructured{testDeployment, testService},
+		},
+		{
+			name: "quoted values",
+			expr: `label.tier in ('frontend', "backend"),name!="test-service"`,
+			want: []unstructured.Unstructured{testDeployment},
+		},
+		{
+			name:    "unknown key",
+			expr:    "color=red",
+			wantErr: "invalid predicate 'color=red': unknown key 'color'",
+		},
+		{
+			name:    "unknown word",
+			expr:    "kind=Deployment,running",
+			wantErr: "unknown requirement 'running'",
+		},
+		{
+			name:    "empty alternative",
+			expr:    "kind=Deployment; ",
+			wantErr: "empty expression",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			predicate, err := ParsePredicate(tt.expr)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, filter(predicate))
+		})
+	}
+}
+
+func filter(predicate Predicate) []unstructured.Unstructured {
+	matched, _ := SplitByPredicates([]unstructured.Unstructured{testDeployment, testWebhook, testCRD, testService}, predicate)
+	return matched
+}
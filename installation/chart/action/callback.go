@@ -52,3 +52,75 @@ func FireAllPostUninstall(actions []PostUninstall, u unstructured.Unstructured)
 	}
 	return done, nil
 }
+
+type PostApply func(*unstructured.Unstructured) error
+
+// PostApplyWithPredicate wraps a PostApply function with predicates to filter which resources the callback is applied to
+func PostApplyWithPredicate(postApplyFunc PostApply, predicate resource.Predicate) PostApply {
+	return func(u *unstructured.Unstructured) error {
+		if predicate(*u) {
+			return postApplyFunc(u)
+		}
+		return nil
+	}
+}
+
+// FireAllPostApply runs all actions on the live object returned by the server after applying it
+func FireAllPostApply(actions []PostApply, u *unstructured.Unstructured) error {
+	for _, f := range actions {
+		if err := f(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PreUninstall is executed before deleting the resource
+// the resource is not deleted until the callback returns true
+type PreUninstall func(u unstructured.Unstructured) (bool, error)
+
+// PreUninstallWithPredicate wraps a PreUninstall function with predicates to filter which resources the callback is applied to
+func PreUninstallWithPredicate(preUninstallFunc PreUninstall, predicate resource.Predicate) PreUninstall {
+	return func(u unstructured.Unstructured) (bool, error) {
+		if predicate(u) {
+			return preUninstallFunc(u)
+		}
+		// if the predicate does not match, consider it done
+		return true, nil
+	}
+}
+
+func FireAllPreUninstall(actions []PreUninstall, u unstructured.Unstructured) (bool, error) {
+	done := true
+	for _, f := range actions {
+		d, err := f(u)
+		if err != nil {
+			return false, err
+		}
+		if !d {
+			done = false
+		}
+	}
+	return done, nil
+}
+
+// PostInstall is executed once after all resources of the release are applied and unused ones are removed
+// it receives live objects returned by the server
+type PostInstall func(objs []unstructured.Unstructured) error
+
+// PostInstallWithPredicate wraps a PostInstall function with predicates to filter which resources are passed to the callback
+func PostInstallWithPredicate(postInstallFunc PostInstall, predicate resource.Predicate) PostInstall {
+	return func(objs []unstructured.Unstructured) error {
+		matched, _ := resource.SplitByPredicates(objs, predicate)
+		return postInstallFunc(matched)
+	}
+}
+
+func FireAllPostInstall(actions []PostInstall, objs []unstructured.Unstructured) error {
+	for _, f := range actions {
+		if err := f(objs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
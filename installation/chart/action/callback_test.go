@@ -89,3 +89,121 @@ func TestPostUninstallWithPredicate(t *testing.T) {
 		require.True(t, done)
 	})
 }
+
+func TestPostApplyWithPredicate(t *testing.T) {
+	t.Run("run only for matching resources", func(t *testing.T) {
+		u := &unstructured.Unstructured{}
+		u.SetKind("TestKind")
+		called := false
+		err := PostApplyWithPredicate(
+			func(u *unstructured.Unstructured) error {
+				called = true
+				return nil
+			},
+			resource.HasKind("TestKind"),
+		)(u)
+		require.NoError(t, err)
+		require.True(t, called)
+	})
+	t.Run("handle error", func(t *testing.T) {
+		u := &unstructured.Unstructured{}
+		u.SetKind("TestKind")
+		testErr := errors.New("test error")
+		err := FireAllPostApply([]PostApply{
+			PostApplyWithPredicate(
+				func(u *unstructured.Unstructured) error {
+					return testErr
+				},
+				resource.HasKind("TestKind"),
+			),
+		}, u)
+		require.ErrorIs(t, err, testErr)
+	})
+	t.Run("skip non-matching resources", func(t *testing.T) {
+		err := PostApplyWithPredicate(
+			func(u *unstructured.Unstructured) error {
+				// should not be called
+				t.Fail()
+				return nil
+			},
+			resource.HasKind("TestKind"),
+		)(&unstructured.Unstructured{})
+		require.NoError(t, err)
+	})
+}
+
+func TestPreUninstallWithPredicate(t *testing.T) {
+	t.Run("run only for matching resources", func(t *testing.T) {
+		u := unstructured.Unstructured{}
+		u.SetKind("TestKind")
+		done, err := FireAllPreUninstall([]PreUninstall{
+			PreUninstallWithPredicate(
+				func(u unstructured.Unstructured) (bool, error) {
+					return false, nil
+				},
+				resource.HasKind("TestKind"),
+			),
+		}, u)
+		require.NoError(t, err)
+		require.False(t, done)
+	})
+	t.Run("handle error", func(t *testing.T) {
+		u := unstructured.Unstructured{}
+		u.SetKind("TestKind")
+		testErr := errors.New("test error")
+		done, err := PreUninstallWithPredicate(
+			func(u unstructured.Unstructured) (bool, error) {
+				return false, testErr
+			},
+			resource.HasKind("TestKind"),
+		)(u)
+		require.ErrorIs(t, err, testErr)
+		require.False(t, done)
+	})
+	t.Run("skip non-matching resources", func(t *testing.T) {
+		u := unstructured.Unstructured{}
+		u.SetKind("OtherKind")
+		done, err := PreUninstallWithPredicate(
+			func(u unstructured.Unstructured) (bool, error) {
+				// should not be called
+				t.Fail()
+				return false, nil
+			},
+			resource.HasKind("TestKind"),
+		)(u)
+		require.NoError(t, err)
+		require.True(t, done)
+	})
+}
+
+func TestPostInstallWithPredicate(t *testing.T) {
+	t.Run("pass only matching resources", func(t *testing.T) {
+		matching := unstructured.Unstructured{}
+		matching.SetKind("TestKind")
+		other := unstructured.Unstructured{}
+		other.SetKind("OtherKind")
+
+		var got []unstructured.Unstructured
+		err := FireAllPostInstall([]PostInstall{
+			PostInstallWithPredicate(
+				func(objs []unstructured.Unstructured) error {
+					got = objs
+					return nil
+				},
+				resource.HasKind("TestKind"),
+			),
+		}, []unstructured.Unstructured{matching, other})
+		require.NoError(t, err)
+		require.Equal(t, []unstructured.Unstructured{matching}, got)
+	})
+	t.Run("handle error", func(t *testing.T) {
+		testErr := errors.New("test error")
+		err := PostInstallWithPredicate(
+			func(objs []unstructured.Unstructured) error {
+				return testErr
+			},
+			resource.HasKind("TestKind"),
+		)(nil)
+		require.ErrorIs(t, err, testErr)
+	})
+}
@@ -0,0 +1,238 @@
+package action
+
+import (
+	"context"
+
+	"github.com/kyma-project/manager-toolkit/installation/base/resource"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Phase describes the lifecycle phase in which the callback is executed
+type Phase string
+
+const (
+	PhasePreApply      Phase = "PreApply"
+	PhasePostApply     Phase = "PostApply"
+	PhasePostInstall   Phase = "PostInstall"
+	PhasePreUninstall  Phase = "PreUninstall"
+	PhasePostUninstall Phase = "PostUninstall"
+)
+
+// CallbackContext provides context-aware callbacks with the state of the running operation
+type CallbackContext struct {
+	// Ctx is the context of the operation and should be used to honour the cancellation
+	Ctx context.Context
+	// Log is scoped to the processed object
+	Log *zap.SugaredLogger
+
+	Client     client.Client
+	RestConfig *rest.Config
+
+	ManagerName      string
+	ReleaseName      string
+	ReleaseNamespace string
+	Phase            Phase
+}
+
+type ContextPreApply func(CallbackContext, *unstructured.Unstructured) error
+
+type ContextPostApply func(CallbackContext, *unstructured.Unstructured) error
+
+type ContextPostInstall func(CallbackContext, []unstructured.Unstructured) error
+
+type ContextPreUninstall func(CallbackContext, unstructured.Unstructured) (bool, error)
+
+type ContextPostUninstall func(CallbackContext, unstructured.Unstructured) (bool, error)
+
+// PreApplyToContext adapts the PreApply to the context-aware callback ignoring the context
+func PreApplyToContext(preApplyFunc PreApply) ContextPreApply {
+	return func(_ CallbackContext, u *unstructured.Unstructured) error {
+		return preApplyFunc(u)
+	}
+}
+
+// PostApplyToContext adapts the PostApply to the context-aware callback ignoring the context
+func PostApplyToContext(postApplyFunc PostApply) ContextPostApply {
+	return func(_ CallbackContext, u *unstructured.Unstructured) error {
+		return postApplyFunc(u)
+	}
+}
+
+// PostInstallToContext adapts the PostInstall to the context-aware callback ignoring the context
+func PostInstallToContext(postInstallFunc PostInstall) ContextPostInstall {
+	return func(_ CallbackContext, objs []unstructured.Unstructured) error {
+		return postInstallFunc(objs)
+	}
+}
+
+// PreUninstallToContext adapts the PreUninstall to the context-aware callback ignoring the context
+func PreUninstallToContext(preUninstallFunc PreUninstall) ContextPreUninstall {
+	return func(_ CallbackContext, u unstructured.Unstructured) (bool, error) {
+		return preUninstallFunc(u)
+	}
+}
+
+// PostUninstallToContext adapts the PostUninstall to the context-aware callback ignoring the context
+func PostUninstallToContext(postUninstallFunc PostUninstall) ContextPostUninstall {
+	return func(_ CallbackContext, u unstructured.Unstructured) (bool, error) {
+		return postUninstallFunc(u)
+	}
+}
+
+// ContextPreApplyWithPredicate wraps a ContextPreApply function with predicates to filter which resources the callback is applied to
+func ContextPreApplyWithPredicate(applyFunc ContextPreApply, predicate resource.Predicate) ContextPreApply {
+	return func(cc CallbackContext, u *unstructured.Unstructured) error {
+		if predicate(*u) {
+			return applyFunc(cc, u)
+		}
+		return nil
+	}
+}
+
+// ContextPostApplyWithPredicate wraps a ContextPostApply function with predicates to filter which resources the callback is applied to
+func ContextPostApplyWithPredicate(postApplyFunc ContextPostApply, predicate resource.Predicate) ContextPostApply {
+	return func(cc CallbackContext, u *unstructured.Unstructured) error {
+		if predicate(*u) {
+			return postApplyFunc(cc, u)
+		}
+		return nil
+	}
+}
+
+// ContextPostInstallWithPredicate wraps a ContextPostInstall function with predicates to filter which resources are passed to the callback
+func ContextPostInstallWithPredicate(postInstallFunc ContextPostInstall, predicate resource.Predicate) ContextPostInstall {
+	return func(cc CallbackContext, objs []unstructured.Unstructured) error {
+		matched, _ := resource.SplitByPredicates(objs, predicate)
+		return postInstallFunc(cc, matched)
+	}
+}
+
+// ContextPreUninstallWithPredicate wraps a ContextPreUninstall function with predicates to filter which resources the callback is applied to
+func ContextPreUninstallWithPredicate(preUninstallFunc ContextPreUninstall, predicate resource.Predicate) ContextPreUninstall {
+	return func(cc CallbackContext, u unstructured.Unstructured) (bool, error) {
+		if predicate(u) {
+			return preUninstallFunc(cc, u)
+		}
+		// if the predicate does not match, consider it done
+		return true, nil
+	}
+}
+
+// ContextPostUninstallWithPredicate wraps a ContextPostUninstall function with predicates to filter which resources the callback is applied to
+func ContextPostUninstallWithPredicate(postUninstallFunc ContextPostUninstall, predicate resource.Predicate) ContextPostUninstall {
+	return func(cc CallbackContext, u unstructured.Unstructured) (bool, error) {
+		if predicate(u) {
+			return postUninstallFunc(cc, u)
+		}
+		// if the predicate does not match, consider it done
+		return true, nil
+	}
+}
+
+// ContextPreApplies returns context-aware callbacks running the given ones first and then the context-aware ones
+func ContextPreApplies(actions []PreApply, contextActions []ContextPreApply) []ContextPreApply {
+	result := make([]ContextPreApply, 0, len(actions)+len(contextActions))
+	for _, f := range actions {
+		result = append(result, PreApplyToContext(f))
+	}
+	return append(result, contextActions...)
+}
+
+// ContextPostApplies returns context-aware callbacks running the given ones first and then the context-aware ones
+func ContextPostApplies(actions []PostApply, contextActions []ContextPostApply) []ContextPostApply {
+	result := make([]ContextPostApply, 0, len(actions)+len(contextActions))
+	for _, f := range actions {
+		result = append(result, PostApplyToContext(f))
+	}
+	return append(result, contextActions...)
+}
+
+// ContextPostInstalls returns context-aware callbacks running the given ones first and then the context-aware ones
+func ContextPostInstalls(actions []PostInstall, contextActions []ContextPostInstall) []ContextPostInstall {
+	result := make([]ContextPostInstall, 0, len(actions)+len(contextActions))
+	for _, f := range actions {
+		result = append(result, PostInstallToContext(f))
+	}
+	return append(result, contextActions...)
+}
+
+// ContextPreUninstalls returns context-aware callbacks running the given ones first and then the context-aware ones
+func ContextPreUninstalls(actions []PreUninstall, contextActions []ContextPreUninstall) []ContextPreUninstall {
+	result := make([]ContextPreUninstall, 0, len(actions)+len(contextActions))
+	for _, f := range actions {
+		result = append(result, PreUninstallToContext(f))
+	}
+	return append(result, contextActions...)
+}
+
+// ContextPostUninstalls returns context-aware callbacks running the given ones first and then the context-aware ones
+func ContextPostUninstalls(actions []PostUninstall, contextActions []ContextPostUninstall) []ContextPostUninstall {
+	result := make([]ContextPostUninstall, 0, len(actions)+len(contextActions))
+	for _, f := range actions {
+		result = append(result, PostUninstallToContext(f))
+	}
+	return append(result, contextActions...)
+}
+
+func FireAllContextPreApply(cc CallbackContext, actions []ContextPreApply, u *unstructured.Unstructured) error {
+	cc.Phase = PhasePreApply
+	for _, f := range actions {
+		if err := f(cc, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func FireAllContextPostApply(cc CallbackContext, actions []ContextPostApply, u *unstructured.Unstructured) error {
+	cc.Phase = PhasePostApply
+	for _, f := range actions {
+		if err := f(cc, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func FireAllContextPostInstall(cc CallbackContext, actions []ContextPostInstall, objs []unstructured.Unstructured) error {
+	cc.Phase = PhasePostInstall
+	for _, f := range actions {
+		if err := f(cc, objs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func FireAllContextPreUninstall(cc CallbackContext, actions []ContextPreUninstall, u unstructured.Unstructured) (bool, error) {
+	cc.Phase = PhasePreUninstall
+	done := true
+	for _, f := range actions {
+		d, err := f(cc, u)
+		if err != nil {
+			return false, err
+		}
+		if !d {
+			done = false
+		}
+	}
+	return done, nil
+}
+
+func FireAllContextPostUninstall(cc CallbackContext, actions []ContextPostUninstall, u unstructured.Unstructured) (bool, error) {
+	cc.Phase = PhasePostUninstall
+	done := true
+	for _, f := range actions {
+		d, err := f(cc, u)
+		if err != nil {
+			return false, err
+		}
+		if !d {
+			done = false
+		}
+	}
+	return done, nil
+}
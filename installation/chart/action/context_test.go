@@ -0,0 +1,150 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kyma-project/manager-toolkit/installation/base/resource"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func fixCallbackContext() CallbackContext {
+	return CallbackContext{
+		Ctx:         context.Background(),
+		Log:         zap.NewNop().Sugar(),
+		ManagerName: "test-manager",
+	}
+}
+
+func TestContextPreApplies(t *testing.T) {
+	t.Run("run adapted actions before context-aware ones", func(t *testing.T) {
+		order := []string{}
+		actions := ContextPreApplies(
+			[]PreApply{func(u *unstructured.Unstructured) error {
+				order = append(order, "legacy")
+				return nil
+			}},
+			[]ContextPreApply{func(cc CallbackContext, u *unstructured.Unstructured) error {
+				require.Equal(t, PhasePreApply, cc.Phase)
+				require.Equal(t, "test-manager", cc.ManagerName)
+				order = append(order, "context")
+				return nil
+			}},
+		)
+
+		err := FireAllContextPreApply(fixCallbackContext(), actions, &unstructured.Unstructured{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"legacy", "context"}, order)
+	})
+	t.Run("handle error", func(t *testing.T) {
+		testErr := errors.New("test error")
+		actions := ContextPreApplies([]PreApply{func(u *unstructured.Unstructured) error {
+			return testErr
+		}}, nil)
+
+		err := FireAllContextPreApply(fixCallbackContext(), actions, &unstructured.Unstructured{})
+		require.ErrorIs(t, err, testErr)
+	})
+}
+
+func TestContextPostApplies(t *testing.T) {
+	u := &unstructured.Unstructured{}
+	u.SetKind("TestKind")
+
+	calls := 0
+	actions := ContextPostApplies(
+		[]PostApply{func(u *unstructured.Unstructured) error {
+			calls++
+			return nil
+		}},
+		[]ContextPostApply{ContextPostApplyWithPredicate(func(cc CallbackContext, u *unstructured.Unstructured) error {
+			require.Equal(t, PhasePostApply, cc.Phase)
+			calls++
+			return nil
+		}, resource.HasKind("TestKind"))},
+	)
+
+	require.NoError(t, FireAllContextPostApply(fixCallbackContext(), actions, u))
+	require.Equal(t, 2, calls)
+}
+
+func TestContextPostInstalls(t *testing.T) {
+	matching := unstructured.Unstructured{}
+	matching.SetKind("TestKind")
+
+	var got []unstructured.Unstructured
+	actions := ContextPostInstalls(nil, []ContextPostInstall{
+		ContextPostInstallWithPredicate(func(cc CallbackContext, objs []unstructured.Unstructured) error {
+			require.Equal(t, PhasePostInstall, cc.Phase)
+			got = objs
+			return nil
+		}, resource.HasKind("TestKind")),
+	})
+
+	err := FireAllContextPostInstall(fixCallbackContext(), actions, []unstructured.Unstructured{matching, {}})
+	require.NoError(t, err)
+	require.Equal(t, []unstructured.Unstructured{matching}, got)
+}
+
+func TestContextPreUninstalls(t *testing.T) {
+	t.Run("wait for not done actions", func(t *testing.T) {
+		actions := ContextPreUninstalls(
+			[]PreUninstall{func(u unstructured.Unstructured) (bool, error) {
+				return true, nil
+			}},
+			[]ContextPreUninstall{func(cc CallbackContext, u unstructured.Unstructured) (bool, error) {
+				require.Equal(t, PhasePreUninstall, cc.Phase)
+				return false, nil
+			}},
+		)
+
+		done, err := FireAllContextPreUninstall(fixCallbackContext(), actions, unstructured.Unstructured{})
+		require.NoError(t, err)
+		require.False(t, done)
+	})
+	t.Run("skip non-matching resources", func(t *testing.T) {
+		actions := ContextPreUninstalls(nil, []ContextPreUninstall{
+			ContextPreUninstallWithPredicate(func(cc CallbackContext, u unstructured.Unstructured) (bool, error) {
+				// should not be called
+				t.Fail()
+				return false, nil
+			}, resource.HasKind("TestKind")),
+		})
+
+		done, err := FireAllContextPreUninstall(fixCallbackContext(), actions, unstructured.Unstructured{})
+		require.NoError(t, err)
+		require.True(t, done)
+	})
+}
+
+func TestContextPostUninstalls(t *testing.T) {
+	t.Run("handle error", func(t *testing.T) {
+		testErr := errors.New("test error")
+		actions := ContextPostUninstalls(
+			[]PostUninstall{func(u unstructured.Unstructured) (bool, error) {
+				return false, testErr
+			}},
+			nil,
+		)
+
+		done, err := FireAllContextPostUninstall(fixCallbackContext(), actions, unstructured.Unstructured{})
+		require.ErrorIs(t, err, testErr)
+		require.False(t, done)
+	})
+	t.Run("skip non-matching resources", func(t *testing.T) {
+		actions := ContextPostUninstalls(nil, []ContextPostUninstall{
+			ContextPostUninstallWithPredicate(func(cc CallbackContext, u unstructured.Unstructured) (bool, error) {
+				// should not be called
+				t.Fail()
+				return false, nil
+			}, resource.HasKind("TestKind")),
+		})
+
+		done, err := FireAllContextPostUninstall(fixCallbackContext(), actions, unstructured.Unstructured{})
+		require.NoError(t, err)
+		require.True(t, done)
+	})
+}
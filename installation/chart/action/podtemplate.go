@@ -0,0 +1,148 @@
+package action
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// podTemplatePath returns the path to the pod template of the workload
+// the pod itself is returned as an empty path and false is returned for objects without pods
+func podTemplatePath(u *unstructured.Unstructured) ([]string, bool) {
+	switch u.GroupVersionKind().GroupKind().String() {
+	case "Deployment.apps", "StatefulSet.apps", "DaemonSet.apps", "ReplicaSet.apps", "Job.batch":
+		return []string{"spec", "template"}, true
+	case "CronJob.batch":
+		return []string{"spec", "jobTemplate", "spec", "template"}, true
+	case "Pod":
+		return []string{}, true
+	default:
+		return nil, false
+	}
+}
+
+// mutatePodSpec runs the mutate function on the unstructured pod spec of the workload
+// the spec is modified in place so fields unknown to the client-go types are kept untouched
+// objects without pods are not modified
+func mutatePodSpec(u *unstructured.Unstructured, mutate func(map[string]interface{}) error) error {
+	path, ok := podTemplatePath(u)
+	if !ok {
+		return nil
+	}
+
+	// manifests decoded from yaml contain int values which can't be deep copied by NestedMap
+	specPath := append(path, "spec")
+	rawSpec, found, err := unstructured.NestedFieldNoCopy(u.Object, specPath...)
+	if err != nil {
+		return fmt.Errorf("could not read pod spec of %s %s/%s: %s", u.GetKind(), u.GetNamespace(), u.GetName(), err.Error())
+	}
+	if !found || rawSpec == nil {
+		rawSpec = map[string]interface{}{}
+		err = unstructured.SetNestedField(u.Object, rawSpec, specPath...)
+		if err != nil {
+			return fmt.Errorf("could not set pod spec of %s %s/%s: %s", u.GetKind(), u.GetNamespace(), u.GetName(), err.Error())
+		}
+	}
+
+	spec, ok := rawSpec.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("pod spec of %s %s/%s is not an object", u.GetKind(), u.GetNamespace(), u.GetName())
+	}
+
+	err = mutate(spec)
+	if err != nil {
+		return fmt.Errorf("could not modify pod spec of %s %s/%s: %s", u.GetKind(), u.GetNamespace(), u.GetName(), err.Error())
+	}
+
+	return nil
+}
+
+// mutateContainers runs the mutate function on all unstructured containers and init containers of the workload
+func mutateContainers(u *unstructured.Unstructured, mutate func(map[string]interface{}) error) error {
+	return mutatePodSpec(u, func(spec map[string]interface{}) error {
+		for _, field := range []string{"initContainers", "containers"} {
+			containers, err := objectList(spec, field)
+			if err != nil {
+				return err
+			}
+
+			for _, container := range containers {
+				err = mutate(container)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// objectList returns objects of the list stored under the field, missing field is returned as an empty list
+func objectList(obj map[string]interface{}, field string) ([]map[string]interface{}, error) {
+	rawList, ok := obj[field]
+	if !ok || rawList == nil {
+		return nil, nil
+	}
+
+	list, ok := rawList.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not a list", field)
+	}
+
+	objs := make([]map[string]interface{}, 0, len(list))
+	for i := range list {
+		item, ok := list[i].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s[%d] is not an object", field, i)
+		}
+		objs = append(objs, item)
+	}
+
+	return objs, nil
+}
+
+// appendToList appends the typed item converted to the unstructured object to the list stored under the field
+func appendToList(obj map[string]interface{}, field string, item interface{}) error {
+	rawItem, err := runtime.DefaultUnstructuredConverter.ToUnstructured(item)
+	if err != nil {
+		return err
+	}
+
+	list, ok := obj[field].([]interface{})
+	if !ok && obj[field] != nil {
+		return fmt.Errorf("%s is not a list", field)
+	}
+
+	obj[field] = append(list, rawItem)
+	return nil
+}
+
+// mergePodTemplateMetadata adds entries to the pod template labels or annotations of the workload
+// the pod's own metadata is modified together with the object metadata
+func mergePodTemplateMetadata(u *unstructured.Unstructured, field string, entries map[string]string) error {
+	path, ok := podTemplatePath(u)
+	if !ok || len(path) == 0 {
+		return nil
+	}
+
+	fieldPath := append(path, "metadata", field)
+	current, _, err := unstructured.NestedStringMap(u.Object, fieldPath...)
+	if err != nil {
+		return fmt.Errorf("could not read pod template %s of %s %s/%s: %s", field, u.GetKind(), u.GetNamespace(), u.GetName(), err.Error())
+	}
+
+	return unstructured.SetNestedStringMap(u.Object, mergeStringMaps(current, entries), fieldPath...)
+}
+
+func mergeStringMaps(current, entries map[string]string) map[string]string {
+	result := make(map[string]string, len(current)+len(entries))
+	for k, v := range current {
+		result[k] = v
+	}
+	for k, v := range entries {
+		result[k] = v
+	}
+
+	return result
+}
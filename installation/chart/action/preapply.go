@@ -0,0 +1,288 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// SetImageRegistry replaces the registry of all container images with the given one
+// images without the registry (e.g. "nginx:1.27") are prefixed with it
+func SetImageRegistry(registry string) PreApply {
+	registry = strings.TrimSuffix(registry, "/")
+	return func(u *unstructured.Unstructured) error {
+		return mutateContainers(u, func(c map[string]interface{}) error {
+			if image, ok := c["image"].(string); ok && image != "" {
+				c["image"] = registry + "/" + imageWithoutRegistry(image)
+			}
+			return nil
+		})
+	}
+}
+
+func imageWithoutRegistry(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[1]
+	}
+
+	return image
+}
+
+// AddImagePullSecrets adds image pull secrets to all workloads skipping already existing ones
+func AddImagePullSecrets(names ...string) PreApply {
+	return func(u *unstructured.Unstructured) error {
+		return mutatePodSpec(u, func(spec map[string]interface{}) error {
+			for _, name := range names {
+				secrets, err := objectList(spec, "imagePullSecrets")
+				if err != nil {
+					return err
+				}
+				if hasImagePullSecret(secrets, name) {
+					continue
+				}
+
+				err = appendToList(spec, "imagePullSecrets", &corev1.LocalObjectReference{Name: name})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+func hasImagePullSecret(secrets []map[string]interface{}, name string) bool {
+	for _, secret := range secrets {
+		if secret["name"] == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddLabels adds labels to all objects and to pod templates of workloads
+func AddLabels(labels map[string]string) PreApply {
+	return func(u *unstructured.Unstructured) error {
+		u.SetLabels(mergeStringMaps(u.GetLabels(), labels))
+		return mergePodTemplateMetadata(u, "labels", labels)
+	}
+}
+
+// AddAnnotations adds annotations to all objects and to pod templates of workloads
+func AddAnnotations(annotations map[string]string) PreApply {
+	return func(u *unstructured.Unstructured) error {
+		u.SetAnnotations(mergeStringMaps(u.GetAnnotations(), annotations))
+		return mergePodTemplateMetadata(u, "annotations", annotations)
+	}
+}
+
+// SetResources sets resource requests and limits of the container with the given name
+// all containers are updated when the name is empty
+func SetResources(containerName string, resources corev1.ResourceRequirements) PreApply {
+	return func(u *unstructured.Unstructured) error {
+		return mutatePodSpec(u, func(spec map[string]interface{}) error {
+			containers, err := objectList(spec, "containers")
+			if err != nil {
+				return err
+			}
+
+			for _, container := range containers {
+				if containerName != "" && container["name"] != containerName {
+					continue
+				}
+
+				rawResources, err := runtime.DefaultUnstructuredConverter.ToUnstructured(resources.DeepCopy())
+				if err != nil {
+					return err
+				}
+				container["resources"] = rawResources
+			}
+			return nil
+		})
+	}
+}
+
+// AddTolerations adds tolerations to all workloads skipping already existing ones
+func AddTolerations(tolerations ...corev1.Toleration) PreApply {
+	return func(u *unstructured.Unstructured) error {
+		return mutatePodSpec(u, func(spec map[string]interface{}) error {
+			for i := range tolerations {
+				rawTolerations, err := objectList(spec, "tolerations")
+				if err != nil {
+					return err
+				}
+
+				exists, err := hasToleration(rawTolerations, &tolerations[i])
+				if err != nil {
+					return err
+				}
+				if exists {
+					continue
+				}
+
+				err = appendToList(spec, "tolerations", &tolerations[i])
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+func hasToleration(rawTolerations []map[string]interface{}, toleration *corev1.Toleration) (bool, error) {
+	for i := range rawTolerations {
+		current := corev1.Toleration{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawTolerations[i], &current)
+		if err != nil {
+			return false, err
+		}
+
+		if current.MatchToleration(toleration) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// SetNodeSelector adds the node selector entries to all workloads
+func SetNodeSelector(nodeSelector map[string]string) PreApply {
+	return func(u *unstructured.Unstructured) error {
+		return mutatePodSpec(u, func(spec map[string]interface{}) error {
+			current, ok := spec["nodeSelector"].(map[string]interface{})
+			if !ok && spec["nodeSelector"] != nil {
+				return fmt.Errorf("nodeSelector is not an object")
+			}
+			if current == nil {
+				current = map[string]interface{}{}
+			}
+
+			for k, v := range nodeSelector {
+				current[k] = v
+			}
+			spec["nodeSelector"] = current
+			return nil
+		})
+	}
+}
+
+// SetPriorityClassName sets the priority class of all workloads
+func SetPriorityClassName(name string) PreApply {
+	return func(u *unstructured.Unstructured) error {
+		return mutatePodSpec(u, func(spec map[string]interface{}) error {
+			spec["priorityClassName"] = name
+			return nil
+		})
+	}
+}
+
+// SetEnv sets environment variables on all containers and init containers
+// variables with the same name are replaced
+func SetEnv(envs ...corev1.EnvVar) PreApply {
+	return func(u *unstructured.Unstructured) error {
+		return mutateContainers(u, func(c map[string]interface{}) error {
+			for i := range envs {
+				err := setEnvVar(c, &envs[i])
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// setEnvVar replaces the container's variable with the same name or appends a new one
+func setEnvVar(container map[string]interface{}, env *corev1.EnvVar) error {
+	envs, err := objectList(container, "env")
+	if err != nil {
+		return err
+	}
+
+	for _, current := range envs {
+		if current["name"] != env.Name {
+			continue
+		}
+
+		rawEnv, err := runtime.DefaultUnstructuredConverter.ToUnstructured(env)
+		if err != nil {
+			return err
+		}
+
+		clear(current)
+		for k, v := range rawEnv {
+			current[k] = v
+		}
+		return nil
+	}
+
+	return appendToList(container, "env", env)
+}
+
+// SetProxyEnv sets proxy environment variables on all containers, empty values are skipped
+func SetProxyEnv(httpProxy, httpsProxy, noProxy string) PreApply {
+	envs := []corev1.EnvVar{}
+	for _, env := range []corev1.EnvVar{
+		{Name: "HTTP_PROXY", Value: httpProxy},
+		{Name: "HTTPS_PROXY", Value: httpsProxy},
+		{Name: "NO_PROXY", Value: noProxy},
+	} {
+		if env.Value != "" {
+			envs = append(envs, env)
+		}
+	}
+
+	return SetEnv(envs...)
+}
+
+// SetFIPSMode sets the FIPS 140-3 mode ("on", "only" or "off") of the Go cryptography on all containers
+// by adding the fips140 setting to the GODEBUG environment variable
+func SetFIPSMode(mode string) PreApply {
+	setting := fmt.Sprintf("fips140=%s", mode)
+	return func(u *unstructured.Unstructured) error {
+		return mutateContainers(u, func(c map[string]interface{}) error {
+			envs, err := objectList(c, "env")
+			if err != nil {
+				return err
+			}
+
+			return setEnvVar(c, &corev1.EnvVar{Name: "GODEBUG", Value: withGODEBUGSetting(envs, setting)})
+		})
+	}
+}
+
+func withGODEBUGSetting(envs []map[string]interface{}, setting string) string {
+	settings := []string{}
+	for _, env := range envs {
+		if env["name"] != "GODEBUG" {
+			continue
+		}
+
+		value, _ := env["value"].(string)
+		for _, s := range strings.Split(value, ",") {
+			if s != "" && !strings.HasPrefix(s, "fips140=") {
+				settings = append(settings, s)
+			}
+		}
+	}
+
+	return strings.Join(append(settings, setting), ",")
+}
+
+// SetReplicas overrides the number of replicas of scalable workloads
+func SetReplicas(replicas int64) PreApply {
+	return func(u *unstructured.Unstructured) error {
+		switch u.GroupVersionKind().GroupKind().String() {
+		case "Deployment.apps", "StatefulSet.apps", "ReplicaSet.apps":
+			return unstructured.SetNestedField(u.Object, replicas, "spec", "replicas")
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package action
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kyma-project/manager-toolkit/installation/base/resource"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const testPodSpec = `
+containers:
+- name: manager
+  image: europe-docker.pkg.dev/kyma-project/prod/manager:1.0.0
+  ports:
+  - containerPort: 8080
+  env:
+  - name: GODEBUG
+    value: http2client=0
+- name: sidecar
+  image: envoy:1.30
+initContainers:
+- name: init
+  image: localhost/init:latest
+`
+
+var testWorkloads = map[string]string{
+	"Deployment":  "apiVersion: apps/v1\nkind: Deployment\nspec:\n  replicas: 1\n  template:\n    spec: %s",
+	"StatefulSet": "apiVersion: apps/v1\nkind: StatefulSet\nspec:\n  replicas: 1\n  template:\n    spec: %s",
+	"DaemonSet":   "apiVersion: apps/v1\nkind: DaemonSet\nspec:\n  template:\n    spec: %s",
+	"Job":         "apiVersion: batch/v1\nkind: Job\nspec:\n  template:\n    spec: %s",
+	"CronJob":     "apiVersion: batch/v1\nkind: CronJob\nspec:\n  jobTemplate:\n    spec:\n      template:\n        spec: %s",
+}
+
+func fixWorkload(t *testing.T, kind string) *unstructured.Unstructured {
+	obj := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal([]byte(fmt.Sprintf(testWorkloads[kind], "{}")), &obj))
+
+	spec := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal([]byte(testPodSpec), &spec))
+
+	// set the decoded spec directly as SetNestedField can't deep copy int values
+	u := &unstructured.Unstructured{Object: obj}
+	path, _ := podTemplatePath(u)
+	template, _, _ := unstructured.NestedFieldNoCopy(u.Object, path...)
+	template.(map[string]interface{})["spec"] = spec
+	u.SetName("test-" + kind)
+	return u
+}
+
+func podSpecOf(t *testing.T, u *unstructured.Unstructured) corev1.PodSpec {
+	path, ok := podTemplatePath(u)
+	require.True(t, ok)
+
+	rawSpec, _, err := unstructured.NestedFieldNoCopy(u.Object, append(path, "spec")...)
+	require.NoError(t, err)
+
+	spec := corev1.PodSpec{}
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(rawSpec.(map[string]interface{}), &spec))
+	return spec
+}
+
+func TestPreApplyLibrary(t *testing.T) {
+	quantity := k8sresource.MustParse("100m")
+	tests := []struct {
+		name   string
+		action PreApply
+		verify func(t *testing.T, u *unstructured.Unstructured, spec corev1.PodSpec)
+	}{
+		{
+			name:   "set image registry",
+			action: SetImageRegistry("registry.local:5000/"),
+			verify: func(t *testing.T, _ *unstructured.Unstructured, spec corev1.PodSpec) {
+				require.Equal(t, "registry.local:5000/kyma-project/prod/manager:1.0.0", spec.Containers[0].Image)
+				require.Equal(t, "registry.local:5000/envoy:1.30", spec.Containers[1].Image)
+				require.Equal(t, "registry.local:5000/init:latest", spec.InitContainers[0].Image)
+			},
+		},
+		{
+			name: "add image pull secrets",
+			action: func(u *unstructured.Unstructured) error {
+				// adding twice doesn't duplicate secrets
+				_ = AddImagePullSecrets("secret")(u)
+				return AddImagePullSecrets("secret", "other")(u)
+			},
+			verify: func(t *testing.T, _ *unstructured.Unstructured, spec corev1.PodSpec) {
+				require.Equal(t, []corev1.LocalObjectReference{{Name: "secret"}, {Name: "other"}}, spec.ImagePullSecrets)
+			},
+		},
+		{
+			name:   "add labels",
+			action: AddLabels(map[string]string{"team": "test"}),
+			verify: func(t *testing.T, u *unstructured.Unstructured, _ corev1.PodSpec) {
+				require.Equal(t, map[string]string{"team": "test"}, u.GetLabels())
+				path, _ := podTemplatePath(u)
+				labels, _, _ := unstructured.NestedStringMap(u.Object, append(path, "metadata", "labels")...)
+				require.Equal(t, map[string]string{"team": "test"}, labels)
+			},
+		},
+		{
+			name:   "add annotations",
+			action: AddAnnotations(map[string]string{"note": "test"}),
+			verify: func(t *testing.T, u *unstructured.Unstructured, _ corev1.PodSpec) {
+				require.Equal(t, map[string]string{"note": "test"}, u.GetAnnotations())
+				path, _ := podTemplatePath(u)
+				annotations, _, _ := unstructured.NestedStringMap(u.Object, append(path, "metadata", "annotations")...)
+				require.Equal(t, map[string]string{"note": "test"}, annotations)
+			},
+		},
+		{
+			name: "set resources",
+			action: SetResources("manager", corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: quantity},
+			}),
+			verify: func(t *testing.T, _ *unstructured.Unstructured, spec corev1.PodSpec) {
+				require.True(t, quantity.Equal(spec.Containers[0].Resources.Requests[corev1.ResourceCPU]))
+				require.Empty(t, spec.Containers[1].Resources.Requests)
+			},
+		},
+		{
+			name: "add tolerations",
+			action: func(u *unstructured.Unstructured) error {
+				toleration := corev1.Toleration{
+					Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "kyma", Effect: corev1.TaintEffectNoSchedule,
+				}
+				// adding twice doesn't duplicate tolerations
+				_ = AddTolerations(toleration)(u)
+				return AddTolerations(toleration, corev1.Toleration{Key: "other", Operator: corev1.TolerationOpExists})(u)
+			},
+			verify: func(t *testing.T, _ *unstructured.Unstructured, spec corev1.PodSpec) {
+				require.Len(t, spec.Tolerations, 2)
+				require.Equal(t, "dedicated", spec.Tolerations[0].Key)
+				require.Equal(t, "other", spec.Tolerations[1].Key)
+			},
+		},
+		{
+			name:   "set node selector",
+			action: SetNodeSelector(map[string]string{"kubernetes.io/arch": "arm64"}),
+			verify: func(t *testing.T, _ *unstructured.Unstructured, spec corev1.PodSpec) {
+				require.Equal(t, map[string]string{"kubernetes.io/arch": "arm64"}, spec.NodeSelector)
+			},
+		},
+		{
+			name:   "set priority class name",
+			action: SetPriorityClassName("kyma-system"),
+			verify: func(t *testing.T, _ *unstructured.Unstructured, spec corev1.PodSpec) {
+				require.Equal(t, "kyma-system", spec.PriorityClassName)
+			},
+		},
+		{
+			name:   "set proxy env",
+			action: SetProxyEnv("http://proxy", "", "localhost"),
+			verify: func(t *testing.T, _ *unstructured.Unstructured, spec corev1.PodSpec) {
+				require.Equal(t, []corev1.EnvVar{
+					{Name: "HTTP_PROXY", Value: "http://proxy"},
+					{Name: "NO_PROXY", Value: "localhost"},
+				}, spec.Containers[1].Env)
+				require.Len(t, spec.InitContainers[0].Env, 2)
+			},
+		},
+		{
+			name:   "set FIPS mode",
+			action: SetFIPSMode("only"),
+			verify: func(t *testing.T, _ *unstructured.Unstructured, spec corev1.PodSpec) {
+				require.Equal(t, []corev1.EnvVar{{Name: "GODEBUG", Value: "http2client=0,fips140=only"}}, spec.Containers[0].Env)
+				require.Equal(t, []corev1.EnvVar{{Name: "GODEBUG", Value: "fips140=only"}}, spec.Containers[1].Env)
+			},
+		},
+	}
+	for _, tt := range tests {
+		for kind := range testWorkloads {
+			t.Run(fmt.Sprintf("%s in %s", tt.name, kind), func(t *testing.T) {
+				u := fixWorkload(t, kind)
+				require.NoError(t, tt.action(u))
+
+				spec := podSpecOf(t, u)
+				require.Equal(t, int64(8080), int64(spec.Containers[0].Ports[0].ContainerPort))
+				tt.verify(t, u, spec)
+			})
+		}
+	}
+}
+
+func TestPreApplyLibrary_keepUnknownFields(t *testing.T) {
+	u := fixWorkload(t, "Deployment")
+	spec, _, _ := unstructured.NestedFieldNoCopy(u.Object, "spec", "template", "spec")
+	spec.(map[string]interface{})["unknownField"] = "keep"
+	containers := spec.(map[string]interface{})["containers"].([]interface{})
+	containers[0].(map[string]interface{})["unknownField"] = "keep"
+
+	actions := []PreApply{
+		SetImageRegistry("registry.local"),
+		SetPriorityClassName("high"),
+		SetEnv(corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}),
+	}
+	require.NoError(t, FireAllPreApply(actions, u))
+
+	require.Equal(t, "keep", spec.(map[string]interface{})["unknownField"])
+	require.Equal(t, map[string]interface{}{
+		"name":  "sidecar",
+		"image": "registry.local/envoy:1.30",
+		"env":   []interface{}{map[string]interface{}{"name": "LOG_LEVEL", "value": "debug"}},
+	}, containers[1])
+	require.Equal(t, "keep", containers[0].(map[string]interface{})["unknownField"])
+	require.NotContains(t, containers[0], "resources")
+}
+
+func TestSetReplicas(t *testing.T) {
+	for kind := range testWorkloads {
+		t.Run(kind, func(t *testing.T) {
+			u := fixWorkload(t, kind)
+			require.NoError(t, SetReplicas(3)(u))
+
+			replicas, found, _ := unstructured.NestedFieldNoCopy(u.Object, "spec", "replicas")
+			if kind == "Deployment" || kind == "StatefulSet" {
+				require.Equal(t, int64(3), replicas)
+			} else {
+				require.False(t, found)
+			}
+		})
+	}
+}
+
+func TestPreApplyLibrary_otherObjects(t *testing.T) {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetName("test")
+
+	actions := []PreApply{
+		SetImageRegistry("registry.local"),
+		AddLabels(map[string]string{"team": "test"}),
+		SetFIPSMode("on"),
+		SetReplicas(2),
+	}
+	require.NoError(t, FireAllPreApply(actions, u))
+	require.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":   "test",
+			"labels": map[string]interface{}{"team": "test"},
+		},
+	}, u.Object)
+}
+
+func TestPreApplyLibrary_withPredicate(t *testing.T) {
+	deploy := fixWorkload(t, "Deployment")
+	job := fixWorkload(t, "Job")
+
+	setRegistry := PreApplyWithPredicate(SetImageRegistry("registry.local"), resource.IsDeployment)
+	require.NoError(t, setRegistry(deploy))
+	require.NoError(t, setRegistry(job))
+
+	require.Equal(t, "registry.local/envoy:1.30", podSpecOf(t, deploy).Containers[1].Image)
+	require.Equal(t, "envoy:1.30", podSpecOf(t, job).Containers[1].Image)
+}
@@ -0,0 +1,151 @@
+package action
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// typedObject is a pointer to the typed Kubernetes object, e.g. *appsv1.Deployment
+type typedObject[T any] interface {
+	*T
+	client.Object
+}
+
+// PreApplyTyped wraps a callback operating on the typed object, e.g. func(*appsv1.Deployment) error
+// the callback is run only for resources of the object's kind registered in the client-go scheme
+func PreApplyTyped[T any, PT typedObject[T]](applyFunc func(PT) error) PreApply {
+	return PreApplyTypedWithScheme(scheme.Scheme, applyFunc)
+}
+
+// PreApplyTypedWithScheme works like the PreApplyTyped but resolves kinds using the given scheme
+// it allows to use typed callbacks for custom resources
+func PreApplyTypedWithScheme[T any, PT typedObject[T]](s *runtime.Scheme, applyFunc func(PT) error) PreApply {
+	gvks, gvksErr := objectKinds[T, PT](s)
+	return func(u *unstructured.Unstructured) error {
+		if gvksErr != nil {
+			return gvksErr
+		}
+		if !hasGVK(gvks, u.GroupVersionKind()) {
+			return nil
+		}
+
+		obj, err := toTyped[T, PT](u)
+		if err != nil {
+			return err
+		}
+
+		err = applyFunc(obj)
+		if err != nil {
+			return err
+		}
+
+		return fromTyped(obj, u)
+	}
+}
+
+// PostUninstallTyped wraps a callback operating on the typed object, e.g. func(*corev1.Secret) (bool, error)
+// the callback is run only for resources of the object's kind registered in the client-go scheme
+func PostUninstallTyped[T any, PT typedObject[T]](postUninstallFunc func(PT) (bool, error)) PostUninstall {
+	return PostUninstallTypedWithScheme(scheme.Scheme, postUninstallFunc)
+}
+
+// PostUninstallTypedWithScheme works like the PostUninstallTyped but resolves kinds using the given scheme
+func PostUninstallTypedWithScheme[T any, PT typedObject[T]](s *runtime.Scheme, postUninstallFunc func(PT) (bool, error)) PostUninstall {
+	gvks, gvksErr := objectKinds[T, PT](s)
+	return func(u unstructured.Unstructured) (bool, error) {
+		if gvksErr != nil {
+			return false, gvksErr
+		}
+		if !hasGVK(gvks, u.GroupVersionKind()) {
+			// other kinds are considered done
+			return true, nil
+		}
+
+		obj, err := toTyped[T, PT](&u)
+		if err != nil {
+			return false, err
+		}
+
+		return postUninstallFunc(obj)
+	}
+}
+
+func objectKinds[T any, PT typedObject[T]](s *runtime.Scheme) ([]schema.GroupVersionKind, error) {
+	gvks, _, err := s.ObjectKinds(PT(new(T)))
+	if err != nil {
+		return nil, fmt.Errorf("while resolving kind of %T: %s", PT(new(T)), err.Error())
+	}
+
+	return gvks, nil
+}
+
+func hasGVK(gvks []schema.GroupVersionKind, gvk schema.GroupVersionKind) bool {
+	for _, g := range gvks {
+		if g == gvk {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toTyped[T any, PT typedObject[T]](u *unstructured.Unstructured) (PT, error) {
+	obj := PT(new(T))
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert %s %s/%s to %T: %s", u.GetKind(), u.GetNamespace(), u.GetName(), obj, err.Error())
+	}
+
+	return obj, nil
+}
+
+// fromTyped writes the typed object back to the unstructured one
+// fields added by the conversion only (e.g. empty status, resources or null creation timestamps) are not propagated
+func fromTyped(obj client.Object, u *unstructured.Unstructured) error {
+	converted, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return fmt.Errorf("could not convert %T to %s %s/%s: %s", obj, u.GetKind(), u.GetNamespace(), u.GetName(), err.Error())
+	}
+
+	removeConversionLeftovers(converted, u.Object)
+	u.Object = converted
+	return nil
+}
+
+// removeConversionLeftovers recursively removes null values and empty objects which are not set in the original object
+func removeConversionLeftovers(converted, original map[string]interface{}) {
+	for key, value := range converted {
+		originalValue, inOriginal := original[key]
+		switch v := value.(type) {
+		case map[string]interface{}:
+			originalMap, _ := originalValue.(map[string]interface{})
+			removeConversionLeftovers(v, originalMap)
+			if len(v) == 0 && !inOriginal {
+				delete(converted, key)
+			}
+		case []interface{}:
+			originalList, _ := originalValue.([]interface{})
+			for i := range v {
+				item, ok := v[i].(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				var originalItem map[string]interface{}
+				if i < len(originalList) {
+					originalItem, _ = originalList[i].(map[string]interface{})
+				}
+				removeConversionLeftovers(item, originalItem)
+			}
+		case nil:
+			if !inOriginal {
+				delete(converted, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package action
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+func TestPreApplyTyped(t *testing.T) {
+	t.Run("update typed object", func(t *testing.T) {
+		u := fixWorkload(t, "Deployment")
+
+		err := PreApplyTyped(func(d *appsv1.Deployment) error {
+			d.Spec.Replicas = ptr.To[int32](5)
+			d.Spec.Template.Spec.Containers[0].Image = "manager:2.0.0"
+			return nil
+		})(u)
+		require.NoError(t, err)
+
+		replicas, _, _ := unstructured.NestedFieldNoCopy(u.Object, "spec", "replicas")
+		require.Equal(t, int64(5), replicas)
+		require.Equal(t, "manager:2.0.0", podSpecOf(t, u).Containers[0].Image)
+		require.Equal(t, "Deployment", u.GetKind())
+		require.Equal(t, "test-Deployment", u.GetName())
+		require.NotContains(t, u.Object, "status")
+		require.NotContains(t, u.Object["metadata"], "creationTimestamp")
+
+		spec, _, _ := unstructured.NestedMap(u.Object, "spec")
+		require.NotContains(t, spec, "strategy")
+		template, _, _ := unstructured.NestedFieldNoCopy(u.Object, "spec", "template")
+		require.NotContains(t, template, "metadata")
+		containers, _, _ := unstructured.NestedFieldNoCopy(u.Object, "spec", "template", "spec", "containers")
+		for _, container := range containers.([]interface{}) {
+			require.NotContains(t, container, "resources")
+		}
+	})
+
+	t.Run("keep empty fields set in the original object", func(t *testing.T) {
+		u := fixWorkload(t, "Deployment")
+		require.NoError(t, unstructured.SetNestedField(u.Object, map[string]interface{}{}, "spec", "strategy"))
+
+		err := PreApplyTyped(func(d *appsv1.Deployment) error {
+			return nil
+		})(u)
+		require.NoError(t, err)
+
+		strategy, found, _ := unstructured.NestedMap(u.Object, "spec", "strategy")
+		require.True(t, found)
+		require.Empty(t, strategy)
+	})
+
+	t.Run("skip other kinds", func(t *testing.T) {
+		u := fixWorkload(t, "StatefulSet")
+
+		err := PreApplyTyped(func(d *appsv1.Deployment) error {
+			// should not be called
+			t.Fail()
+			return nil
+		})(u)
+		require.NoError(t, err)
+	})
+
+	t.Run("handle error", func(t *testing.T) {
+		testErr := errors.New("test error")
+
+		err := PreApplyTyped(func(d *appsv1.Deployment) error {
+			return testErr
+		})(fixWorkload(t, "Deployment"))
+		require.ErrorIs(t, err, testErr)
+	})
+
+	t.Run("type not registered in the scheme", func(t *testing.T) {
+		err := PreApplyTypedWithScheme(runtime.NewScheme(), func(d *appsv1.Deployment) error {
+			return nil
+		})(fixWorkload(t, "Deployment"))
+		require.ErrorContains(t, err, "while resolving kind of *v1.Deployment")
+	})
+}
+
+func TestPostUninstallTyped(t *testing.T) {
+	secret := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "test-secret"},
+		"type":       "Opaque",
+	}}
+
+	t.Run("run for typed object", func(t *testing.T) {
+		done, err := PostUninstallTyped(func(s *corev1.Secret) (bool, error) {
+			require.Equal(t, "test-secret", s.GetName())
+			require.Equal(t, corev1.SecretTypeOpaque, s.Type)
+			return false, nil
+		})(secret)
+		require.NoError(t, err)
+		require.False(t, done)
+	})
+
+	t.Run("skip other kinds", func(t *testing.T) {
+		done, err := PostUninstallTyped(func(s *corev1.ConfigMap) (bool, error) {
+			// should not be called
+			t.Fail()
+			return false, nil
+		})(secret)
+		require.NoError(t, err)
+		require.True(t, done)
+	})
+
+	t.Run("handle error", func(t *testing.T) {
+		testErr := errors.New("test error")
+
+		done, err := PostUninstallTyped(func(s *corev1.Secret) (bool, error) {
+			return false, testErr
+		})(secret)
+		require.ErrorIs(t, err, testErr)
+		require.False(t, done)
+	})
+}
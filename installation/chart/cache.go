@@ -1,9 +1,16 @@
 package chart
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
 	"sync"
 
+	"helm.sh/helm/v3/pkg/release"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,6 +27,17 @@ var (
 	emptyContextManifest = ContextManifest{}
 )
 
+const (
+	// specSecretKey keeps the uncompressed spec stored by previous versions, it's only read
+	specSecretKey           = "spec"
+	compressedSpecSecretKey = "compressedSpec"
+	historySecretKey        = "history"
+
+	// maxSecretDataSize limits data stored in the cache Secret, it's lower than 1MiB limit of the Secret size
+	// to leave a room for the Secret metadata
+	maxSecretDataSize = 1000 * 1024
+)
+
 type ManifestCache interface {
 	Set(context.Context, client.ObjectKey, ContextManifest) error
 	Get(context.Context, client.ObjectKey) (ContextManifest, error)
@@ -66,7 +84,9 @@ func (r *inMemoryManifestCache) Delete(_ context.Context, key client.ObjectKey)
 
 // secretManifestCache - provides an Secret based processor to store ContextManifest.
 //
-// Inside the secret we store manifest and flags used to render it.
+// Inside the secret we store compressed manifest and flags used to render it.
+// The history of revisions is compressed and stored separately, the oldest revisions are dropped
+// when the Secret would exceed maxSecretDataSize.
 type secretManifestCache struct {
 	client client.Client
 }
@@ -76,6 +96,141 @@ type ContextManifest struct {
 	ManagerUID  string
 	CustomFlags map[string]interface{}
 	Manifest    string
+
+	// Values are effective values used to render the Manifest
+	// they contain values from all sources merged with the CustomFlags
+	Values map[string]interface{}
+
+	// ChartDigest is a hash of the chart content used to render the Manifest
+	ChartDigest string
+	// PostRendererDigest is a hash of the post renderer configuration used to render the Manifest
+	PostRendererDigest string
+
+	// Subcharts are paths of subcharts enabled by conditions and tags while rendering the Manifest
+	Subcharts []string
+
+	// Hooks are chart hooks rendered together with the Manifest
+	Hooks []*release.Hook
+	// PendingHooks is the event of hooks which have to be completed after the Manifest is applied
+	PendingHooks release.HookEvent
+
+	// ApplyTimes are times of the last changes made by the manager's apply to objects, read from their managed fields
+	// they're used to find objects changed by the following installation without reading them from the cluster
+	ApplyTimes map[string]string
+
+	// Revision is the number of the currently deployed manifest revision
+	Revision int
+	// Timestamp is the time when the current revision has been deployed
+	Timestamp metav1.Time
+	// History contains previous revisions of the manifest ordered from the oldest one
+	// it's filled only when the Config.MaxHistory is greater than 0
+	History []ManifestRevision
+}
+
+// RevisionOutcome describes the result of the manifest revision deployment
+type RevisionOutcome string
+
+const (
+	// RevisionDeployed marks the revision that is currently applied on the cluster
+	RevisionDeployed RevisionOutcome = "Deployed"
+
+	// RevisionSuperseded marks the revision replaced by a newer one
+	RevisionSuperseded RevisionOutcome = "Superseded"
+
+	// RevisionFailed marks the revision that could not be applied on the cluster
+	RevisionFailed RevisionOutcome = "Failed"
+)
+
+// ManifestRevision contains a single revision of the rendered manifest with the context used to render it
+// hooks are not part of the revision, they are kept only for the currently deployed manifest
+type ManifestRevision struct {
+	Revision    int
+	ManagerUID  string
+	CustomFlags map[string]interface{}
+	Values      map[string]interface{}
+	Manifest    string
+	ChartDigest string
+	// PostRendererDigest is kept to not render the chart again right after the rollback to the revision
+	PostRendererDigest string
+	Subcharts          []string
+	Timestamp          metav1.Time
+	Outcome            RevisionOutcome
+}
+
+// Revisions returns all known revisions, the oldest first and the currently deployed one last.
+func (cm ContextManifest) Revisions() []ManifestRevision {
+	revisions := append([]ManifestRevision{}, cm.History...)
+	if cm.Revision == 0 && cm.Manifest == "" {
+		// nothing is deployed yet
+		return revisions
+	}
+
+	return append(revisions, cm.currentRevision())
+}
+
+func (cm ContextManifest) currentRevision() ManifestRevision {
+	return ManifestRevision{
+		Revision:           cm.Revision,
+		ManagerUID:         cm.ManagerUID,
+		CustomFlags:        cm.CustomFlags,
+		Values:             cm.Values,
+		Manifest:           cm.Manifest,
+		ChartDigest:        cm.ChartDigest,
+		PostRendererDigest: cm.PostRendererDigest,
+		Subcharts:          cm.Subcharts,
+		Timestamp:          cm.Timestamp,
+		Outcome:            RevisionDeployed,
+	}
+}
+
+// deepCopy returns the copy of the ContextManifest not sharing maps, slices and hooks with the original one
+func (cm ContextManifest) deepCopy() ContextManifest {
+	clone := cm
+	clone.CustomFlags = deepCopyMap(cm.CustomFlags)
+	clone.Values = deepCopyMap(cm.Values)
+	clone.Subcharts = slices.Clone(cm.Subcharts)
+	clone.Hooks = deepCopyHooks(cm.Hooks)
+	clone.ApplyTimes = maps.Clone(cm.ApplyTimes)
+
+	if cm.History != nil {
+		clone.History = make([]ManifestRevision, len(cm.History))
+		for i, revision := range cm.History {
+			revision.CustomFlags = deepCopyMap(revision.CustomFlags)
+			revision.Values = deepCopyMap(revision.Values)
+			revision.Subcharts = slices.Clone(revision.Subcharts)
+			clone.History[i] = revision
+		}
+	}
+
+	return clone
+}
+
+func deepCopyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+
+	return deepCopyValue(m).(map[string]interface{})
+}
+
+func deepCopyHooks(hooks []*release.Hook) []*release.Hook {
+	if hooks == nil {
+		return nil
+	}
+
+	clone := make([]*release.Hook, len(hooks))
+	for i, hook := range hooks {
+		if hook == nil {
+			continue
+		}
+
+		hookClone := *hook
+		hookClone.Events = slices.Clone(hook.Events)
+		hookClone.DeletePolicies = slices.Clone(hook.DeletePolicies)
+		clone[i] = &hookClone
+	}
+
+	return clone
 }
 
 // NewSecretManifestCache - returns a new instance of SecretManifestCache.
@@ -109,29 +264,62 @@ func (m *secretManifestCache) Get(ctx context.Context, key client.ObjectKey) (Co
 	}
 
 	spec := ContextManifest{}
-	err = json.Unmarshal(secret.Data["spec"], &spec)
+	if byteSpec, ok := secret.Data[compressedSpecSecretKey]; ok {
+		err = decompressJSON(byteSpec, &spec)
+	} else {
+		err = json.Unmarshal(secret.Data[specSecretKey], &spec)
+	}
 	if err != nil {
 		return emptyContextManifest, err
 	}
 
+	if byteHistory, ok := secret.Data[historySecretKey]; ok {
+		spec.History = []ManifestRevision{}
+		err = decompressJSON(byteHistory, &spec.History)
+		if err != nil {
+			return emptyContextManifest, fmt.Errorf("could not read revisions history: %s", err.Error())
+		}
+	}
+
 	return spec, nil
 }
 
 // Set - saves the passed flags and manifest into Secret based on the client.ObjectKey.
 func (m *secretManifestCache) Set(ctx context.Context, key client.ObjectKey, spec ContextManifest) error {
-	byteSpec, err := json.Marshal(&spec)
+	history := spec.History
+	spec.History = nil
+
+	byteSpec, err := compressJSON(&spec)
 	if err != nil {
 		return err
 	}
+	if len(byteSpec) > maxSecretDataSize {
+		return fmt.Errorf("manifest is too big to be stored in the cache: %d compressed bytes, limit is %d bytes", len(byteSpec), maxSecretDataSize)
+	}
+
+	data := map[string][]byte{
+		compressedSpecSecretKey: byteSpec,
+	}
+
+	// drop the oldest revisions until the history fits into the Secret
+	for ; len(history) > 0; history = history[1:] {
+		byteHistory, err := compressJSON(history)
+		if err != nil {
+			return err
+		}
+
+		if len(byteSpec)+len(byteHistory) <= maxSecretDataSize {
+			data[historySecretKey] = byteHistory
+			break
+		}
+	}
 
 	secret := corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      key.Name,
 			Namespace: key.Namespace,
 		},
-		Data: map[string][]byte{
-			"spec": byteSpec,
-		},
+		Data: data,
 	}
 
 	err = m.client.Update(ctx, &secret)
@@ -141,3 +329,41 @@ func (m *secretManifestCache) Set(ctx context.Context, key client.ObjectKey, spe
 
 	return m.client.Create(ctx, &secret)
 }
+
+// compressJSON returns gzipped JSON of the value
+func compressJSON(value interface{}) ([]byte, error) {
+	byteValue, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.Buffer{}
+	writer := gzip.NewWriter(&buf)
+	_, err = writer.Write(byteValue)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decompressJSON unmarshals gzipped JSON into the value
+func decompressJSON(data []byte, value interface{}) error {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	byteValue, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byteValue, value)
+}
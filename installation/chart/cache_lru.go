@@ -0,0 +1,117 @@
+package chart
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ ManifestCache = (*lruManifestCache)(nil)
+
+// CacheStats contains counters describing the lruManifestCache efficiency
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// lruManifestCache is a cache-aside decorator for any ManifestCache. It keeps recently used ContextManifests
+// in memory to avoid reading (and decoding) them from the underlying cache during every reconciliation.
+//
+// Entries are refreshed on Set, invalidated on Delete and expire after the configured ttl
+// to pick up changes done to the underlying cache by other processes.
+// Stored and returned ContextManifests are deep copied, so callers can modify them safely.
+type lruManifestCache struct {
+	cache ManifestCache
+	lru   *lruCache[client.ObjectKey, ContextManifest]
+
+	// generation is increased by every Set and Delete to not store entries read before concurrent writes
+	mu         sync.Mutex
+	generation uint64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// NewLRUManifestCache returns a new instance of lruManifestCache keeping up to size entries for ttl.
+// Zero ttl means that entries are kept until they are evicted, updated or deleted.
+// Size lower than 1 disables the in-memory caching, so all calls go to the underlying cache.
+func NewLRUManifestCache(cache ManifestCache, size int, ttl time.Duration) *lruManifestCache {
+	return &lruManifestCache{
+		cache: cache,
+		lru:   newLRUCache[client.ObjectKey, ContextManifest](size, ttl),
+	}
+}
+
+// Get loads the ContextManifest from memory or from the underlying cache if it's not there.
+func (c *lruManifestCache) Get(ctx context.Context, key client.ObjectKey) (ContextManifest, error) {
+	if spec, ok := c.lru.get(key); ok {
+		c.hits.Add(1)
+		return spec.deepCopy(), nil
+	}
+
+	c.misses.Add(1)
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	spec, err := c.cache.Get(ctx, key)
+	if err != nil {
+		return emptyContextManifest, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation == c.generation {
+		// no Set or Delete has been completed in the meantime, so the read spec is up-to-date
+		c.store(key, spec)
+	}
+	return spec, nil
+}
+
+// Set saves the ContextManifest in the underlying cache and refreshes the in-memory entry.
+func (c *lruManifestCache) Set(ctx context.Context, key client.ObjectKey, spec ContextManifest) error {
+	err := c.cache.Set(ctx, key, spec)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if err != nil {
+		// the underlying cache state is unknown
+		c.lru.delete(key)
+		return err
+	}
+
+	c.store(key, spec)
+	return nil
+}
+
+// Delete removes the ContextManifest from the underlying cache and invalidates the in-memory entry.
+func (c *lruManifestCache) Delete(ctx context.Context, key client.ObjectKey) error {
+	err := c.cache.Delete(ctx, key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.lru.delete(key)
+	return err
+}
+
+// Stats returns hits, misses and evictions counted since the cache has been created.
+func (c *lruManifestCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+func (c *lruManifestCache) store(key client.ObjectKey, spec ContextManifest) {
+	if c.lru.set(key, spec.deepCopy()) {
+		c.evictions.Add(1)
+	}
+}
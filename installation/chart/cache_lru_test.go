@@ -0,0 +1,151 @@
+package chart
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type countingManifestCache struct {
+	ManifestCache
+	gets   int
+	setErr error
+	// onGet is called after reading the spec from the underlying cache
+	onGet func()
+}
+
+func (c *countingManifestCache) Get(ctx context.Context, key client.ObjectKey) (ContextManifest, error) {
+	c.gets++
+	spec, err := c.ManifestCache.Get(ctx, key)
+	if c.onGet != nil {
+		c.onGet()
+	}
+	return spec, err
+}
+
+func (c *countingManifestCache) Set(ctx context.Context, key client.ObjectKey, spec ContextManifest) error {
+	if c.setErr != nil {
+		return c.setErr
+	}
+	return c.ManifestCache.Set(ctx, key, spec)
+}
+
+func TestLRUManifestCache(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-name", Namespace: testSecretNamespace}
+	otherKey := types.NamespacedName{Name: "other-name", Namespace: testSecretNamespace}
+
+	t.Run("read underlying cache only once", func(t *testing.T) {
+		inner := &countingManifestCache{ManifestCache: NewInMemoryManifestCache()}
+		require.NoError(t, inner.Set(ctx, key, ContextManifest{Manifest: testDeploy}))
+		cache := NewLRUManifestCache(inner, 2, 0)
+
+		for range 3 {
+			spec, err := cache.Get(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, testDeploy, spec.Manifest)
+		}
+
+		require.Equal(t, 1, inner.gets)
+		require.Equal(t, CacheStats{Hits: 2, Misses: 1}, cache.Stats())
+	})
+
+	t.Run("refresh entry on set", func(t *testing.T) {
+		inner := &countingManifestCache{ManifestCache: NewInMemoryManifestCache()}
+		cache := NewLRUManifestCache(inner, 2, 0)
+
+		require.NoError(t, cache.Set(ctx, key, ContextManifest{Manifest: testDeploy}))
+		spec, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, testDeploy, spec.Manifest)
+		require.Equal(t, 0, inner.gets)
+	})
+
+	t.Run("invalidate entry on set error", func(t *testing.T) {
+		inner := &countingManifestCache{ManifestCache: NewInMemoryManifestCache()}
+		cache := NewLRUManifestCache(inner, 2, 0)
+		require.NoError(t, cache.Set(ctx, key, ContextManifest{Manifest: testDeploy}))
+
+		inner.setErr = errors.New("test error")
+		require.Error(t, cache.Set(ctx, key, ContextManifest{Manifest: testCRD}))
+
+		spec, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, testDeploy, spec.Manifest)
+		require.Equal(t, 1, inner.gets)
+	})
+
+	t.Run("invalidate entry on delete", func(t *testing.T) {
+		inner := &countingManifestCache{ManifestCache: NewInMemoryManifestCache()}
+		cache := NewLRUManifestCache(inner, 2, 0)
+		require.NoError(t, cache.Set(ctx, key, ContextManifest{Manifest: testDeploy}))
+
+		require.NoError(t, cache.Delete(ctx, key))
+
+		spec, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, emptyContextManifest, spec)
+		require.Equal(t, 1, inner.gets)
+	})
+
+	t.Run("don't store entry read before concurrent set", func(t *testing.T) {
+		inner := &countingManifestCache{ManifestCache: NewInMemoryManifestCache()}
+		require.NoError(t, inner.Set(ctx, key, ContextManifest{Manifest: testDeploy}))
+		cache := NewLRUManifestCache(inner, 2, 0)
+		inner.onGet = func() {
+			inner.onGet = nil
+			require.NoError(t, cache.Set(ctx, key, ContextManifest{Manifest: testCRD}))
+		}
+
+		spec, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, testDeploy, spec.Manifest)
+
+		spec, err = cache.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, testCRD, spec.Manifest)
+		require.Equal(t, 1, inner.gets)
+	})
+
+	t.Run("don't share stored entries", func(t *testing.T) {
+		cache := NewLRUManifestCache(NewInMemoryManifestCache(), 2, 0)
+		spec := ContextManifest{CustomFlags: map[string]interface{}{"replicas": 1}}
+		require.NoError(t, cache.Set(ctx, key, spec))
+		spec.CustomFlags["replicas"] = 2
+
+		cached, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		cached.CustomFlags["replicas"] = 3
+
+		cached, err = cache.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"replicas": 1}, cached.CustomFlags)
+	})
+
+	t.Run("disable caching for zero size", func(t *testing.T) {
+		inner := &countingManifestCache{ManifestCache: NewInMemoryManifestCache()}
+		cache := NewLRUManifestCache(inner, 0, 0)
+		require.NoError(t, cache.Set(ctx, key, ContextManifest{Manifest: testDeploy}))
+
+		for range 2 {
+			spec, err := cache.Get(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, testDeploy, spec.Manifest)
+		}
+		require.Equal(t, 2, inner.gets)
+		require.Equal(t, CacheStats{Misses: 2}, cache.Stats())
+	})
+
+	t.Run("count evictions", func(t *testing.T) {
+		cache := NewLRUManifestCache(NewInMemoryManifestCache(), 1, 0)
+
+		require.NoError(t, cache.Set(ctx, key, ContextManifest{Manifest: testDeploy}))
+		require.NoError(t, cache.Set(ctx, otherKey, ContextManifest{Manifest: testCRD}))
+
+		require.Equal(t, uint64(1), cache.Stats().Evictions)
+	})
+}
@@ -2,7 +2,10 @@ package chart
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -188,8 +191,9 @@ func TestManifestCache_Set(t *testing.T) {
 		require.NoError(t, client.Get(ctx, key, &secret))
 
 		actualSpec := ContextManifest{}
-		err = json.Unmarshal(secret.Data["spec"], &actualSpec)
+		err = decompressJSON(secret.Data["compressedSpec"], &actualSpec)
 		require.NoError(t, err)
+		require.NotContains(t, secret.Data, "spec")
 
 		require.Equal(t, expectedSpec, actualSpec)
 	})
@@ -219,12 +223,97 @@ func TestManifestCache_Set(t *testing.T) {
 		require.NoError(t, client.Get(ctx, key, &secret))
 
 		actualSpec := ContextManifest{}
-		err = json.Unmarshal(secret.Data["spec"], &actualSpec)
+		err = decompressJSON(secret.Data["compressedSpec"], &actualSpec)
 		require.NoError(t, err)
+		require.NotContains(t, secret.Data, "spec")
 
 		require.Equal(t, expectedSpec, actualSpec)
 	})
 
+	t.Run("store compressed history", func(t *testing.T) {
+		key := types.NamespacedName{
+			Name:      "test-name",
+			Namespace: testSecretNamespace,
+		}
+		ctx := context.TODO()
+		client := fake.NewClientBuilder().Build()
+
+		cache := NewSecretManifestCache(client)
+		expectedSpec := ContextManifest{
+			Manifest: "schmetterling",
+			Revision: 2,
+			History: []ManifestRevision{
+				{Revision: 1, Manifest: "raupe", Outcome: RevisionSuperseded},
+			},
+		}
+		require.NoError(t, cache.Set(ctx, key, expectedSpec))
+
+		var secret corev1.Secret
+		require.NoError(t, client.Get(ctx, key, &secret))
+		require.Contains(t, secret.Data, "history")
+
+		storedSpec := ContextManifest{}
+		require.NoError(t, decompressJSON(secret.Data["compressedSpec"], &storedSpec))
+		require.Empty(t, storedSpec.History)
+
+		actualSpec, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, expectedSpec, actualSpec)
+	})
+
+	t.Run("drop the oldest revisions exceeding the size limit", func(t *testing.T) {
+		key := types.NamespacedName{
+			Name:      "test-name",
+			Namespace: testSecretNamespace,
+		}
+		ctx := context.TODO()
+		client := fake.NewClientBuilder().Build()
+
+		cache := NewSecretManifestCache(client)
+		spec := ContextManifest{Manifest: "schmetterling", Revision: 5}
+		for i := 1; i < 5; i++ {
+			spec.History = append(spec.History, ManifestRevision{
+				Revision: i,
+				Manifest: fixRandomManifest(t, 300*1024),
+				Outcome:  RevisionSuperseded,
+			})
+		}
+		require.NoError(t, cache.Set(ctx, key, spec))
+
+		actualSpec, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		require.Len(t, actualSpec.History, 3)
+		require.Equal(t, 2, actualSpec.History[0].Revision)
+		require.Equal(t, 4, actualSpec.History[2].Revision)
+	})
+
+	t.Run("manifest exceeding the size limit", func(t *testing.T) {
+		key := types.NamespacedName{
+			Name:      "test-name",
+			Namespace: testSecretNamespace,
+		}
+		cache := NewSecretManifestCache(fake.NewClientBuilder().Build())
+
+		err := cache.Set(context.TODO(), key, ContextManifest{Manifest: fixRandomManifest(t, 1400*1024)})
+		require.ErrorContains(t, err, "manifest is too big to be stored in the cache")
+	})
+
+	t.Run("store compressed manifest bigger than the size limit", func(t *testing.T) {
+		key := types.NamespacedName{
+			Name:      "test-name",
+			Namespace: testSecretNamespace,
+		}
+		ctx := context.TODO()
+		cache := NewSecretManifestCache(fake.NewClientBuilder().Build())
+		expectedSpec := ContextManifest{Manifest: strings.Repeat("schmetterling", 100*1024)}
+
+		require.NoError(t, cache.Set(ctx, key, expectedSpec))
+
+		actualSpec, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, expectedSpec, actualSpec)
+	})
+
 	t.Run("marshal error", func(t *testing.T) {
 		key := types.NamespacedName{
 			Name:      "test-name",
@@ -260,3 +349,12 @@ func fixSecretCache(t *testing.T, key types.NamespacedName, spec ContextManifest
 		},
 	}
 }
+
+// fixRandomManifest returns a manifest which can't be compressed much
+func fixRandomManifest(t *testing.T, size int) string {
+	data := make([]byte, size*3/4)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,58 @@
+package chart
+
+import (
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/client-go/discovery"
+)
+
+// Capabilities pins the Kubernetes version and API versions available for templates
+// (.Capabilities.KubeVersion and .Capabilities.APIVersions) instead of discovering them from the cluster
+type Capabilities struct {
+	// KubeVersion is the Kubernetes version, e.g. "v1.33.0"
+	// Helm's default version is used when empty
+	KubeVersion string
+
+	// APIVersions are additional group versions or group version kinds, e.g. "monitoring.coreos.com/v1/ServiceMonitor"
+	// available next to Helm's default API versions
+	APIVersions []string
+}
+
+func (c *Capabilities) toHelmCapabilities() (*chartutil.Capabilities, error) {
+	capabilities := chartutil.DefaultCapabilities.Copy()
+	if c.KubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(c.KubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("while parsing kube version '%s': %s", c.KubeVersion, err.Error())
+		}
+		capabilities.KubeVersion = *kubeVersion
+	}
+	capabilities.APIVersions = append(capabilities.APIVersions, c.APIVersions...)
+
+	return capabilities, nil
+}
+
+func discoverCapabilities(discoveryClient discovery.DiscoveryInterface) (*chartutil.Capabilities, error) {
+	kubeVersion, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("while getting server version: %s", err.Error())
+	}
+
+	// orphaned API services are ignored by the GetVersionSet and don't prevent us from using other API versions
+	apiVersions, err := action.GetVersionSet(discoveryClient)
+	if err != nil {
+		return nil, fmt.Errorf("while getting api versions: %s", err.Error())
+	}
+
+	return &chartutil.Capabilities{
+		APIVersions: apiVersions,
+		KubeVersion: chartutil.KubeVersion{
+			Version: kubeVersion.GitVersion,
+			Major:   kubeVersion.Major,
+			Minor:   kubeVersion.Minor,
+		},
+		HelmVersion: chartutil.DefaultCapabilities.HelmVersion,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package chart
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestCapabilities_toHelmCapabilities(t *testing.T) {
+	t.Run("pin kube version and api versions", func(t *testing.T) {
+		capabilities, err := (&Capabilities{
+			KubeVersion: "v1.33.1",
+			APIVersions: []string{"monitoring.coreos.com/v1/ServiceMonitor"},
+		}).toHelmCapabilities()
+		require.NoError(t, err)
+
+		require.Equal(t, "v1.33.1", capabilities.KubeVersion.Version)
+		require.Equal(t, "33", capabilities.KubeVersion.Minor)
+		require.True(t, capabilities.APIVersions.Has("monitoring.coreos.com/v1/ServiceMonitor"))
+		require.True(t, capabilities.APIVersions.Has("apps/v1"))
+	})
+
+	t.Run("use default kube version", func(t *testing.T) {
+		capabilities, err := (&Capabilities{}).toHelmCapabilities()
+		require.NoError(t, err)
+		require.Equal(t, chartutil.DefaultCapabilities.KubeVersion, capabilities.KubeVersion)
+	})
+
+	t.Run("wrong kube version", func(t *testing.T) {
+		_, err := (&Capabilities{KubeVersion: "latest"}).toHelmCapabilities()
+		require.ErrorContains(t, err, "while parsing kube version 'latest'")
+	})
+}
+
+func Test_clientGetter_ToCapabilities(t *testing.T) {
+	t.Run("discover and cache capabilities", func(t *testing.T) {
+		discoveryClient := fixFakeDiscovery()
+		now := time.Now()
+		getter := &clientGetter{
+			discoveryClient: memory.NewMemCacheClient(discoveryClient),
+			now:             func() time.Time { return now },
+		}
+
+		capabilities, err := getter.ToCapabilities()
+		require.NoError(t, err)
+		require.Equal(t, "v1.33.1", capabilities.KubeVersion.Version)
+		require.True(t, capabilities.APIVersions.Has("monitoring.coreos.com/v1"))
+		require.True(t, capabilities.APIVersions.Has("monitoring.coreos.com/v1/ServiceMonitor"))
+
+		_, err = getter.ToCapabilities()
+		require.NoError(t, err)
+		require.Equal(t, 1, countVersionRequests(discoveryClient))
+
+		now = now.Add(capabilitiesTTL + time.Second)
+		_, err = getter.ToCapabilities()
+		require.NoError(t, err)
+		require.Equal(t, 2, countVersionRequests(discoveryClient))
+	})
+
+	t.Run("share getter for the same config", func(t *testing.T) {
+		config := &rest.Config{Host: "https://localhost:6443"}
+		require.Same(t, getClientGetter(config), getClientGetter(config))
+		require.NotSame(t, getClientGetter(config), getClientGetter(&rest.Config{Host: "https://localhost:6443"}))
+	})
+
+	t.Run("release getters of unused configs", func(t *testing.T) {
+		config := &rest.Config{Host: "https://localhost:6443"}
+		getter := getClientGetter(config)
+		for i := 0; i < clientGettersSize; i++ {
+			getClientGetter(&rest.Config{Host: "https://localhost:6443"})
+		}
+
+		require.NotSame(t, getter, getClientGetter(config))
+	})
+}
+
+func fixFakeDiscovery() *fakediscovery.FakeDiscovery {
+	fake := &clienttesting.Fake{
+		Resources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "monitoring.coreos.com/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "servicemonitors", Kind: "ServiceMonitor"},
+				},
+			},
+		},
+	}
+
+	return &fakediscovery.FakeDiscovery{
+		Fake: fake,
+		FakedServerVersion: &version.Info{
+			GitVersion: "v1.33.1",
+			Major:      "1",
+			Minor:      "33",
+		},
+	}
+}
+
+func countVersionRequests(discoveryClient *fakediscovery.FakeDiscovery) int {
+	count := 0
+	for _, action := range discoveryClient.Actions() {
+		if action.GetResource().Resource == "version" {
+			count++
+		}
+	}
+	return count
+}
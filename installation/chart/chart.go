@@ -2,22 +2,26 @@ package chart
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
-	"reflect"
 	"strings"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/kube"
 	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage"
 	"helm.sh/helm/v3/pkg/storage/driver"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -30,12 +34,49 @@ type Config struct {
 	ManagerName string
 	Cluster     Cluster
 	Release     Release
+
+	// MaxHistory limits the number of previous manifest revisions kept in the cache
+	// history is disabled when set to 0
+	MaxHistory int
+
+	// EventRecorder emits Events about the installation, uninstallation and verification on the EventObject
+	// e.g. the module CR, events are disabled when any of them is not set
+	// events are emitted during every reconciliation, recorders created by the record.EventBroadcaster
+	// aggregate them by the object and reason and rate-limit them so they don't flood the cluster
+	EventRecorder record.EventRecorder
+	EventObject   runtime.Object
+
+	// TracerProvider creates spans for the installation, uninstallation and verification
+	// the global provider is used when not set, which is a no-op one until the application configures it
+	TracerProvider trace.TracerProvider
 }
 
 type Release struct {
+	// ChartPath is a path to the local chart directory or archive
+	// it's used when the Source is not set
 	ChartPath string
+	// Source provides the chart from other places like embedded file systems or OCI registries
+	Source    ChartSource
 	Name      string
 	Namespace string
+	// Capabilities pins the Kubernetes version and API versions used to render the chart
+	// they are discovered from the cluster when not set
+	Capabilities *Capabilities
+}
+
+// chartSource returns the configured chart source or nil if the chart is not configured
+func (r Release) chartSource() ChartSource {
+	if r.Source != nil {
+		return r.Source
+	}
+	if strings.HasSuffix(r.ChartPath, ".tgz") || strings.HasSuffix(r.ChartPath, ".tar.gz") {
+		return NewArchiveChartSource(r.ChartPath)
+	}
+	if r.ChartPath != "" {
+		return NewDirChartSource(r.ChartPath)
+	}
+
+	return nil
 }
 
 type Cluster struct {
@@ -43,7 +84,59 @@ type Cluster struct {
 	Config *rest.Config
 }
 
+// parsedManifests memoizes parsed manifests by their hash as the same manifest is parsed during every reconciliation
+var parsedManifests = newLRUCache[string, []unstructured.Unstructured](parsedManifestsCacheSize, 0)
+
+const parsedManifestsCacheSize = 32
+
 func parseManifest(manifest string) ([]unstructured.Unstructured, error) {
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(manifest)))
+	if objs, ok := parsedManifests.get(hash); ok {
+		return deepCopyObjects(objs), nil
+	}
+
+	objs, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedManifests.set(hash, objs)
+	return deepCopyObjects(objs), nil
+}
+
+// deepCopyObjects protects memoized objects from being modified by callers
+func deepCopyObjects(objs []unstructured.Unstructured) []unstructured.Unstructured {
+	result := make([]unstructured.Unstructured, len(objs))
+	for i := range objs {
+		result[i] = unstructured.Unstructured{
+			Object: deepCopyValue(objs[i].Object).(map[string]interface{}),
+		}
+	}
+
+	return result
+}
+
+// deepCopyValue works like runtime.DeepCopyJSONValue but accepts all scalar types produced by the yaml decoder
+func deepCopyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			clone[key] = deepCopyValue(elem)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, elem := range v {
+			clone[i] = deepCopyValue(elem)
+		}
+		return clone
+	default:
+		return v
+	}
+}
+
+func decodeManifest(manifest string) ([]unstructured.Unstructured, error) {
 	results := make([]unstructured.Unstructured, 0)
 	decoder := yaml.NewDecoder(strings.NewReader(manifest))
 
@@ -65,10 +158,7 @@ func parseManifest(manifest string) ([]unstructured.Unstructured, error) {
 		}
 
 		u := unstructured.Unstructured{Object: obj}
-		// some resources need to be applied first (before workloads)
-		// if this statement gets bigger then extract it to the separated place
-		if u.GetObjectKind().GroupVersionKind().Kind == "CustomResourceDefinition" ||
-			u.GetObjectKind().GroupVersionKind().Kind == "PriorityClass" {
+		if isInstalledFirst(u) {
 			results = append([]unstructured.Unstructured{u}, results...)
 			continue
 		}
@@ -78,39 +168,104 @@ func parseManifest(manifest string) ([]unstructured.Unstructured, error) {
 	return results, nil
 }
 
-func getCachedAndCurrentManifest(config *Config, customFlags map[string]interface{}, renderChartFunc func(config *Config, customFlags map[string]interface{}) (*release.Release, error)) (string, string, error) {
-	cachedSpecManifest, err := config.Cache.Get(config.Ctx, config.CacheKey)
+// isInstalledFirst returns true for resources which need to be applied first (before workloads)
+func isInstalledFirst(u unstructured.Unstructured) bool {
+	kind := u.GetObjectKind().GroupVersionKind().Kind
+	return kind == "CustomResourceDefinition" || kind == "PriorityClass"
+}
+
+// getCachedAndCurrentManifest returns the cached spec and the current one and whether the chart has been rendered
+// instead of using the cached manifest
+func getCachedAndCurrentManifest(config *Config, opts *InstallOpts, renderChartFunc func(config *Config, customFlags map[string]interface{}) (*release.Release, error)) (ContextManifest, ContextManifest, bool, error) {
+	cacheConfig, span := startSpan(config, "chart.cache.get")
+	cachedSpec, err := config.Cache.Get(cacheConfig.Ctx, config.CacheKey)
+	endSpan(span, err)
 	if err != nil {
-		return "", "", fmt.Errorf("could not get manifest from cache : %s", err.Error())
+		return emptyContextManifest, emptyContextManifest, false, fmt.Errorf("could not get manifest from cache : %s", err.Error())
 	}
 
-	if !shouldRenderAgain(cachedSpecManifest, config, customFlags) {
-		return cachedSpecManifest.Manifest, cachedSpecManifest.Manifest, nil
+	chartDigest, err := getChartDigest(config)
+	if err != nil {
+		return cachedSpec, emptyContextManifest, false, err
 	}
 
-	currentRelease, err := renderChartFunc(config, customFlags)
+	values, err := mergeValues(config.Ctx, config.Cluster, opts.Values, opts.CustomFlags)
 	if err != nil {
-		return cachedSpecManifest.Manifest, "", fmt.Errorf("could not render manifest : %s", err.Error())
+		return cachedSpec, emptyContextManifest, false, fmt.Errorf("could not merge values : %s", err.Error())
 	}
 
-	return cachedSpecManifest.Manifest, currentRelease.Manifest, nil
+	rendererDigest, isPostRendererCacheable := postRendererDigest(opts.PostRenderer)
+	currentSpec := ContextManifest{
+		ManagerUID:         config.ManagerUID,
+		CustomFlags:        opts.CustomFlags,
+		Values:             values,
+		ChartDigest:        chartDigest,
+		PostRendererDigest: rendererDigest,
+	}
+
+	if !opts.ForceRender && isPostRendererCacheable && !shouldRenderAgain(cachedSpec, currentSpec) {
+		currentSpec.Manifest = cachedSpec.Manifest
+		currentSpec.Hooks = cachedSpec.Hooks
+		currentSpec.Subcharts = cachedSpec.Subcharts
+		return cachedSpec, currentSpec, false, nil
+	}
+
+	renderConfig, span := startSpan(config, "chart.render")
+	renderConfig.Ctx = withChartDigest(renderConfig.Ctx, chartDigest)
+	currentRelease, err := renderChartFunc(renderConfig, values)
+	if err != nil {
+		err = fmt.Errorf("could not render manifest : %s", err.Error())
+		endSpan(span, err)
+		return cachedSpec, emptyContextManifest, true, err
+	}
+
+	currentSpec.Manifest, err = postRenderManifest(opts.PostRenderer, currentRelease.Manifest)
+	endSpan(span, err)
+	if err != nil {
+		return cachedSpec, emptyContextManifest, true, err
+	}
+	currentSpec.Hooks = currentRelease.Hooks
+	currentSpec.Subcharts = enabledSubcharts(currentRelease.Chart)
+	return cachedSpec, currentSpec, true, nil
+}
+
+func getChartDigest(config *Config) (string, error) {
+	source := config.Release.chartSource()
+	if source == nil {
+		// nothing to hash
+		return "", nil
+	}
+
+	return source.Digest(config.Ctx)
+}
+
+func shouldRenderAgain(cachedSpec ContextManifest, currentSpec ContextManifest) bool {
+	// cachedSpec is up-to-date only if flags used to render, the chart content, the post renderer and manager is the same one who rendered it before
+	return cachedSpec.ManagerUID != currentSpec.ManagerUID ||
+		cachedSpec.ChartDigest != currentSpec.ChartDigest ||
+		cachedSpec.PostRendererDigest != currentSpec.PostRendererDigest ||
+		!equalFlags(cachedSpec.CustomFlags, currentSpec.CustomFlags) ||
+		!equalValues(cachedSpec.Values, currentSpec.Values)
 }
 
-func shouldRenderAgain(cachedSpec ContextManifest, config *Config, customFlags map[string]interface{}) bool {
-	// cachedSpec is up-to-date only if flags used to render and manager is the same one who rendered it before
-	equalFlags := reflect.DeepEqual(cachedSpec.CustomFlags, customFlags)
-	return cachedSpec.ManagerUID != config.ManagerUID || !equalFlags
+// equalFlags compares flags the same way as values because typed flags (e.g. int or []string)
+// are decoded as int64 or []interface{} after being stored in the cache
+func equalFlags(flags, otherFlags map[string]interface{}) bool {
+	return equalValues(flags, otherFlags)
 }
 
-func renderChart(config *Config, customFlags map[string]interface{}) (*release.Release, error) {
-	chart, err := loader.Load(config.Release.ChartPath)
+func renderChart(config *Config, values map[string]interface{}) (*release.Release, error) {
+	chart, err := loadChart(config.Ctx, config.Release)
 	if err != nil {
-		return nil, fmt.Errorf("while loading chart from path '%s': %s", config.Release.ChartPath, err.Error())
+		return nil, err
 	}
 
-	installAction := newInstallAction(config)
+	installAction, err := newInstallAction(config)
+	if err != nil {
+		return nil, err
+	}
 
-	rel, err := installAction.Run(chart, customFlags)
+	rel, err := installAction.Run(chart, values)
 	if err != nil {
 		return nil, fmt.Errorf("while templating chart: %s", err.Error())
 	}
@@ -118,9 +273,26 @@ func renderChart(config *Config, customFlags map[string]interface{}) (*release.R
 	return rel, nil
 }
 
-func newInstallAction(config *Config) *action.Install {
-	helmRESTGetter := &clientGetter{
-		config: config.Cluster.Config,
+func loadChart(ctx context.Context, release Release) (*chart.Chart, error) {
+	source := release.chartSource()
+	if source == nil {
+		return nil, fmt.Errorf("chart path or source is not configured")
+	}
+
+	ch, err := source.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ch, validateDependencies(ch)
+}
+
+func newInstallAction(config *Config) (*action.Install, error) {
+	helmRESTGetter := getClientGetter(config.Cluster.Config)
+
+	capabilities, err := getCapabilities(config, helmRESTGetter)
+	if err != nil {
+		return nil, fmt.Errorf("while resolving cluster capabilities: %s", err.Error())
 	}
 
 	helmClient := kube.New(helmRESTGetter)
@@ -132,6 +304,7 @@ func newInstallAction(config *Config) *action.Install {
 
 	actionConfig.Releases = storage.Init(driver.NewMemory())
 	actionConfig.RESTClientGetter = helmRESTGetter
+	actionConfig.Capabilities = capabilities
 
 	action := action.NewInstall(actionConfig)
 	action.ReleaseName = config.Release.Name
@@ -140,5 +313,14 @@ func newInstallAction(config *Config) *action.Install {
 	action.IsUpgrade = true
 	action.DryRun = true
 
-	return action
+	return action, nil
+}
+
+// getCapabilities returns capabilities pinned in the release or discovered from the cluster
+func getCapabilities(config *Config, getter *clientGetter) (*chartutil.Capabilities, error) {
+	if config.Release.Capabilities != nil {
+		return config.Release.Capabilities.toHelmCapabilities()
+	}
+
+	return getter.ToCapabilities()
 }
@@ -2,10 +2,14 @@ package chart
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
+	"github.com/stretchr/testify/require"
 	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func Test_getOrRenderManifestWithRenderer(t *testing.T) {
@@ -20,6 +24,8 @@ func Test_getOrRenderManifestWithRenderer(t *testing.T) {
 	type args struct {
 		config          *Config
 		customFlags     map[string]interface{}
+		forceRender     bool
+		values          []ValuesSource
 		renderChartFunc func(config *Config, customFlags map[string]interface{}) (*release.Release, error)
 	}
 	tests := []struct {
@@ -70,21 +76,129 @@ func Test_getOrRenderManifestWithRenderer(t *testing.T) {
 			want:    "test-new-manifest-2",
 			wantErr: false,
 		},
+		{
+			name: "render manifest when chart is changed",
+			args: args{
+				renderChartFunc: fixManifestRenderFunc("test-new-manifest-3"),
+				config: &Config{
+					Ctx:      context.Background(),
+					Cache:    cache,
+					CacheKey: noCRDManifestKey,
+					Release: Release{
+						ChartPath: "testdata/test-chart",
+					},
+				},
+			},
+			want:    "test-new-manifest-3",
+			wantErr: false,
+		},
+		{
+			name: "render manifest when values are changed",
+			args: args{
+				renderChartFunc: fixManifestRenderFunc("test-new-manifest-5"),
+				values: []ValuesSource{
+					ValuesFromMap(map[string]interface{}{"replicas": 2}),
+				},
+				config: &Config{
+					Ctx:      context.Background(),
+					Cache:    cache,
+					CacheKey: noCRDManifestKey,
+				},
+			},
+			want:    "test-new-manifest-5",
+			wantErr: false,
+		},
+		{
+			name: "render manifest when forced",
+			args: args{
+				renderChartFunc: fixManifestRenderFunc("test-new-manifest-4"),
+				forceRender:     true,
+				config: &Config{
+					Ctx:      context.Background(),
+					Cache:    cache,
+					CacheKey: noCRDManifestKey,
+				},
+			},
+			want:    "test-new-manifest-4",
+			wantErr: false,
+		},
+		{
+			name: "chart not found",
+			args: args{
+				config: &Config{
+					Ctx:      context.Background(),
+					Cache:    cache,
+					CacheKey: noCRDManifestKey,
+					Release: Release{
+						ChartPath: "testdata/not-existing-chart",
+					},
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, gotCurrent, err := getCachedAndCurrentManifest(tt.args.config, tt.args.customFlags, tt.args.renderChartFunc)
+			opts := &InstallOpts{CustomFlags: tt.args.customFlags, Values: tt.args.values, ForceRender: tt.args.forceRender}
+			_, gotCurrent, _, err := getCachedAndCurrentManifest(tt.args.config, opts, tt.args.renderChartFunc)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getCachedAndCurrentManifest() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if gotCurrent != tt.want {
-				t.Errorf("getCachedAndCurrentManifest() = %v, want %v", gotCurrent, tt.want)
+			if gotCurrent.Manifest != tt.want {
+				t.Errorf("getCachedAndCurrentManifest() = %v, want %v", gotCurrent.Manifest, tt.want)
 			}
 		})
 	}
 }
 
+func Test_shouldRenderAgain(t *testing.T) {
+	t.Run("typed flags stored in the secret cache", func(t *testing.T) {
+		key := types.NamespacedName{Name: "test-name", Namespace: testSecretNamespace}
+		cache := NewSecretManifestCache(fake.NewClientBuilder().Build())
+
+		flags, err := NewFlagsBuilder().
+			With("replicas", 2).
+			With("resources.limits.cpu", 0.5).
+			WithList("global.imagePullSecrets", []string{"secret-1", "secret-2"}).
+			WithMap("global.commonLabels", map[string]string{"app": "test"}).
+			Build()
+		require.NoError(t, err)
+
+		currentSpec := ContextManifest{ManagerUID: "uid", CustomFlags: flags, Manifest: testDeploy}
+		require.NoError(t, cache.Set(context.Background(), key, currentSpec))
+
+		cachedSpec, err := cache.Get(context.Background(), key)
+		require.NoError(t, err)
+		require.False(t, shouldRenderAgain(cachedSpec, currentSpec))
+	})
+}
+
+func Test_parseManifest(t *testing.T) {
+	t.Run("return objects in install order", func(t *testing.T) {
+		objs, err := parseManifest(fmt.Sprint(testDeploy, separator, testCRD))
+		require.NoError(t, err)
+		require.Len(t, objs, 2)
+		require.Equal(t, "CustomResourceDefinition", objs[0].GetKind())
+		require.Equal(t, "Deployment", objs[1].GetKind())
+	})
+
+	t.Run("memoized objects are not modified by callers", func(t *testing.T) {
+		manifest := fmt.Sprint(testServiceAccount, separator, testDeploy, "spec:\n  replicas: 2\n")
+
+		objs, err := parseManifest(manifest)
+		require.NoError(t, err)
+		objs[0].SetLabels(map[string]string{"modified": "true"})
+		require.NoError(t, unstructured.SetNestedField(objs[1].Object, int64(5), "spec", "replicas"))
+
+		objs, err = parseManifest(manifest)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"label-key": "label-val"}, objs[0].GetLabels())
+		replicas, _, _ := unstructured.NestedFieldNoCopy(objs[1].Object, "spec", "replicas")
+		require.Equal(t, 2, replicas)
+	})
+}
+
 func fixManifestRenderFunc(manifest string) func(config *Config, customFlags map[string]interface{}) (*release.Release, error) {
 	return func(config *Config, customFlags map[string]interface{}) (*release.Release, error) {
 		return &release.Release{
@@ -1,7 +1,11 @@
 package chart
 
 import (
+	"sync"
+	"time"
+
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/discovery/cached/memory"
@@ -12,8 +16,47 @@ import (
 
 var _ action.RESTClientGetter = &clientGetter{}
 
+const (
+	// capabilitiesTTL defines how long discovered cluster capabilities are reused between renders
+	capabilitiesTTL = 5 * time.Minute
+
+	// clientGettersSize and clientGettersTTL limit the number of kept clientGetters
+	// so getters of rest.Configs which are not used anymore are released
+	clientGettersSize = 32
+	clientGettersTTL  = time.Hour
+)
+
+var (
+	// clientGetters keeps one clientGetter per rest.Config to share the discovery cache across renders
+	clientGetters   = newLRUCache[*rest.Config, *clientGetter](clientGettersSize, clientGettersTTL)
+	clientGettersMu sync.Mutex
+)
+
 type clientGetter struct {
 	config *rest.Config
+
+	mu                    sync.Mutex
+	discoveryClient       discovery.CachedDiscoveryInterface
+	capabilities          *chartutil.Capabilities
+	capabilitiesExpiresAt time.Time
+	now                   func() time.Time
+}
+
+// getClientGetter returns clientGetter shared by all renders using the same rest.Config
+func getClientGetter(config *rest.Config) *clientGetter {
+	clientGettersMu.Lock()
+	defer clientGettersMu.Unlock()
+
+	if getter, ok := clientGetters.get(config); ok {
+		return getter
+	}
+
+	getter := &clientGetter{
+		config: config,
+		now:    time.Now,
+	}
+	clientGetters.set(config, getter)
+	return getter
 }
 
 func (cg *clientGetter) ToRESTConfig() (*rest.Config, error) {
@@ -21,8 +64,20 @@ func (cg *clientGetter) ToRESTConfig() (*rest.Config, error) {
 }
 
 func (cg *clientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
-	discoveryClient, _ := discovery.NewDiscoveryClientForConfig(cg.config)
-	return memory.NewMemCacheClient(discoveryClient), nil
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	if cg.discoveryClient != nil {
+		return cg.discoveryClient, nil
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cg.config)
+	if err != nil {
+		return nil, err
+	}
+
+	cg.discoveryClient = memory.NewMemCacheClient(discoveryClient)
+	return cg.discoveryClient, nil
 }
 
 func (cg *clientGetter) ToRESTMapper() (meta.RESTMapper, error) {
@@ -42,3 +97,30 @@ func (cg *clientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
 	overrides := &clientcmd.ConfigOverrides{ClusterDefaults: clientcmd.ClusterDefaults}
 	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
 }
+
+// ToCapabilities returns the Kubernetes version and API versions discovered from the cluster.
+// The result is reused for capabilitiesTTL to not query the discovery API during every render.
+func (cg *clientGetter) ToCapabilities() (*chartutil.Capabilities, error) {
+	discoveryClient, err := cg.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	if cg.capabilities != nil && cg.now().Before(cg.capabilitiesExpiresAt) {
+		return cg.capabilities.Copy(), nil
+	}
+
+	// fetch the latest server version and resources, e.g. recently installed CRDs
+	discoveryClient.Invalidate()
+	capabilities, err := discoverCapabilities(discoveryClient)
+	if err != nil {
+		return nil, err
+	}
+
+	cg.capabilities = capabilities
+	cg.capabilitiesExpiresAt = cg.now().Add(capabilitiesTTL)
+	return capabilities.Copy(), nil
+}
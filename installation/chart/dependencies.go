@@ -0,0 +1,96 @@
+package chart
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// validateDependencies checks if all dependencies declared in the Chart.yaml are present in the charts/ directory
+// and match declared versions, subcharts are validated recursively
+func validateDependencies(ch *chart.Chart) error {
+	problems := dependencyProblems(ch, "")
+	if len(problems) > 0 {
+		return fmt.Errorf("chart '%s' has invalid dependencies: %s", ch.Name(), strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+func dependencyProblems(ch *chart.Chart, prefix string) []string {
+	subcharts := map[string]*chart.Chart{}
+	for _, subchart := range ch.Dependencies() {
+		subcharts[subchart.Name()] = subchart
+	}
+
+	problems := []string{}
+	for _, dependency := range ch.Metadata.Dependencies {
+		name := prefix + dependency.Name
+		subchart, ok := subcharts[dependency.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing subchart %s", formatDependency(name, dependency.Version)))
+			continue
+		}
+
+		if err := checkDependencyVersion(subchart, dependency); err != nil {
+			problems = append(problems, fmt.Sprintf("subchart %s: %s", name, err.Error()))
+		}
+	}
+
+	for _, subchart := range ch.Dependencies() {
+		problems = append(problems, dependencyProblems(subchart, prefix+subchart.Name()+"/")...)
+	}
+
+	return problems
+}
+
+func checkDependencyVersion(subchart *chart.Chart, dependency *chart.Dependency) error {
+	if dependency.Version == "" {
+		return nil
+	}
+
+	constraint, err := semver.NewConstraint(dependency.Version)
+	if err != nil {
+		return fmt.Errorf("invalid version constraint '%s': %s", dependency.Version, err.Error())
+	}
+
+	version, err := semver.NewVersion(subchart.Metadata.Version)
+	if err != nil {
+		return fmt.Errorf("invalid version '%s': %s", subchart.Metadata.Version, err.Error())
+	}
+
+	if !constraint.Check(version) {
+		return fmt.Errorf("version %s does not match '%s'", version, dependency.Version)
+	}
+
+	return nil
+}
+
+func formatDependency(name, version string) string {
+	if version == "" {
+		return name
+	}
+
+	return fmt.Sprintf("%s (%s)", name, version)
+}
+
+// enabledSubcharts returns sorted paths of subcharts left in the chart after processing conditions and tags
+func enabledSubcharts(ch *chart.Chart) []string {
+	if ch == nil {
+		return nil
+	}
+
+	var subcharts []string
+	for _, subchart := range ch.Dependencies() {
+		subcharts = append(subcharts, subchart.Name())
+		for _, nested := range enabledSubcharts(subchart) {
+			subcharts = append(subcharts, subchart.Name()+"/"+nested)
+		}
+	}
+
+	sort.Strings(subcharts)
+	return subcharts
+}
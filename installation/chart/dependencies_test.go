@@ -0,0 +1,142 @@
+package chart
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func fixChart(name, version string, dependencies []*chart.Dependency, subcharts ...*chart.Chart) *chart.Chart {
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:   chart.APIVersionV2,
+			Name:         name,
+			Version:      version,
+			Dependencies: dependencies,
+		},
+	}
+	ch.SetDependencies(subcharts...)
+
+	return ch
+}
+
+func Test_validateDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		chart   *chart.Chart
+		wantErr string
+	}{
+		{
+			name:  "no dependencies",
+			chart: fixChart("main", "1.0.0", nil),
+		},
+		{
+			name: "all dependencies present",
+			chart: fixChart("main", "1.0.0",
+				[]*chart.Dependency{{Name: "sub", Version: "^1.0.0"}, {Name: "other"}},
+				fixChart("sub", "1.2.0", nil), fixChart("other", "0.0.1", nil)),
+		},
+		{
+			name: "missing dependencies",
+			chart: fixChart("main", "1.0.0",
+				[]*chart.Dependency{{Name: "sub", Version: "^1.0.0"}, {Name: "other"}, {Name: "present"}},
+				fixChart("present", "1.0.0", nil)),
+			wantErr: "chart 'main' has invalid dependencies: missing subchart sub (^1.0.0), missing subchart other",
+		},
+		{
+			name: "version mismatch",
+			chart: fixChart("main", "1.0.0",
+				[]*chart.Dependency{{Name: "sub", Version: "~1.2.0"}},
+				fixChart("sub", "1.3.0", nil)),
+			wantErr: "chart 'main' has invalid dependencies: subchart sub: version 1.3.0 does not match '~1.2.0'",
+		},
+		{
+			name: "invalid constraint",
+			chart: fixChart("main", "1.0.0",
+				[]*chart.Dependency{{Name: "sub", Version: "one"}},
+				fixChart("sub", "1.0.0", nil)),
+			wantErr: "subchart sub: invalid version constraint 'one'",
+		},
+		{
+			name: "missing nested dependency",
+			chart: fixChart("main", "1.0.0",
+				[]*chart.Dependency{{Name: "sub"}},
+				fixChart("sub", "1.0.0", []*chart.Dependency{{Name: "nested", Version: "1.0.0"}})),
+			wantErr: "missing subchart sub/nested (1.0.0)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDependencies(tt.chart)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func Test_enabledSubcharts(t *testing.T) {
+	t.Run("no chart", func(t *testing.T) {
+		require.Nil(t, enabledSubcharts(nil))
+	})
+
+	t.Run("nested subcharts", func(t *testing.T) {
+		ch := fixChart("main", "1.0.0", nil,
+			fixChart("b", "1.0.0", nil, fixChart("nested", "1.0.0", nil)),
+			fixChart("a", "1.0.0", nil))
+
+		require.Equal(t, []string{"a", "b", "b/nested"}, enabledSubcharts(ch))
+	})
+}
+
+func TestRender_dependencies(t *testing.T) {
+	release := Release{
+		ChartPath: "testdata/umbrella-chart",
+		Name:      "umbrella",
+		Namespace: "default",
+	}
+
+	t.Run("render subcharts enabled by conditions and tags", func(t *testing.T) {
+		objs, err := Render(release, nil, RenderOpts{})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"umbrella-umbrella", "umbrella-database"}, namesOf(objs))
+
+		objs, err = Render(release, map[string]interface{}{
+			"database": map[string]interface{}{"enabled": false},
+			"tags":     map[string]interface{}{"monitoring": true},
+		}, RenderOpts{})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"umbrella-umbrella", "umbrella-metrics"}, namesOf(objs))
+	})
+}
+
+func Test_getCachedAndCurrentManifest_subcharts(t *testing.T) {
+	config := &Config{
+		Ctx:        context.Background(),
+		Cache:      NewInMemoryManifestCache(),
+		ManagerUID: "uid",
+	}
+	renderFunc := func(_ *Config, _ map[string]interface{}) (*release.Release, error) {
+		return &release.Release{
+			Chart: fixChart("main", "1.0.0", nil, fixChart("sub", "1.0.0", nil)),
+		}, nil
+	}
+
+	_, currentSpec, _, err := getCachedAndCurrentManifest(config, &InstallOpts{}, renderFunc)
+	require.NoError(t, err)
+	require.Equal(t, []string{"sub"}, currentSpec.Subcharts)
+}
+
+func namesOf(objs []unstructured.Unstructured) []string {
+	names := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		names = append(names, obj.GetName())
+	}
+	return names
+}
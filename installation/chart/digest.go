@@ -0,0 +1,73 @@
+package chart
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// chartDirDigest returns hash of all files (templates, values, Chart.yaml, etc.) inside the chart directory
+// it allows detecting chart changes even if the manager has not been restarted
+func chartDirDigest(chartPath string) (string, error) {
+	return fsDigest(os.DirFS(chartPath))
+}
+
+// fsDigest returns hash of paths and content of all files in the given file system
+func fsDigest(fsys fs.FS) (string, error) {
+	hash := sha256.New()
+	err := walkFiles(fsys, func(name string) error {
+		file, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		fmt.Fprintf(hash, "%s\x00", name)
+		_, err = io.Copy(hash, file)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("while calculating chart digest: %s", err.Error())
+	}
+
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+// walkFiles calls fn for all regular files in the file system following symlinks the same way as the helm loader
+// files are walked in lexical order, so the result is deterministic
+func walkFiles(fsys fs.FS, fn func(name string) error) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		mode := d.Type()
+		if mode&fs.ModeSymlink != 0 {
+			info, err := fs.Stat(fsys, name)
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				linkedFS, err := fs.Sub(fsys, name)
+				if err != nil {
+					return err
+				}
+
+				return walkFiles(linkedFS, func(linkedName string) error {
+					return fn(path.Join(name, linkedName))
+				})
+			}
+			mode = info.Mode()
+		}
+
+		if !mode.IsRegular() {
+			return nil
+		}
+
+		return fn(name)
+	})
+}
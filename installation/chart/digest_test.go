@@ -0,0 +1,66 @@
+package chart
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_chartDirDigest(t *testing.T) {
+	t.Run("stable digest", func(t *testing.T) {
+		digest, err := chartDirDigest("testdata/test-chart")
+		require.NoError(t, err)
+		require.Regexp(t, "^sha256:[0-9a-f]{64}$", digest)
+
+		otherDigest, err := chartDirDigest("testdata/test-chart")
+		require.NoError(t, err)
+		require.Equal(t, digest, otherDigest)
+	})
+
+	t.Run("digest changes with the chart content", func(t *testing.T) {
+		chartPath := t.TempDir()
+		require.NoError(t, os.CopyFS(chartPath, os.DirFS("testdata/test-chart")))
+
+		digest, err := chartDirDigest(chartPath)
+		require.NoError(t, err)
+
+		valuesPath := filepath.Join(chartPath, "values.yaml")
+		require.NoError(t, os.WriteFile(valuesPath, []byte("replicas: 2\n"), 0o600))
+
+		changedDigest, err := chartDirDigest(chartPath)
+		require.NoError(t, err)
+		require.NotEqual(t, digest, changedDigest)
+	})
+
+	t.Run("digest changes with the content of symlinked files", func(t *testing.T) {
+		chartPath := filepath.Join(t.TempDir(), "chart")
+		require.NoError(t, os.CopyFS(chartPath, os.DirFS("testdata/test-chart")))
+		linkedPath := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(linkedPath, "values.yaml"), []byte("replicas: 1\n"), 0o600))
+		require.NoError(t, os.Remove(filepath.Join(chartPath, "values.yaml")))
+		require.NoError(t, os.Symlink(filepath.Join(linkedPath, "values.yaml"), filepath.Join(chartPath, "values.yaml")))
+		require.NoError(t, os.Symlink(linkedPath, filepath.Join(chartPath, "linked")))
+
+		digest, err := chartDirDigest(chartPath)
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(filepath.Join(linkedPath, "values.yaml"), []byte("replicas: 2\n"), 0o600))
+
+		changedDigest, err := chartDirDigest(chartPath)
+		require.NoError(t, err)
+		require.NotEqual(t, digest, changedDigest)
+
+		require.NoError(t, os.WriteFile(filepath.Join(linkedPath, "other.yaml"), []byte("replicas: 2\n"), 0o600))
+
+		otherDigest, err := chartDirDigest(chartPath)
+		require.NoError(t, err)
+		require.NotEqual(t, changedDigest, otherDigest)
+	})
+
+	t.Run("chart not found", func(t *testing.T) {
+		_, err := chartDirDigest("testdata/not-existing-chart")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,93 @@
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// EventReasonInstalled is used when the installation has changed objects in the cluster
+	EventReasonInstalled = "Installed"
+	// EventReasonInstallFailed is used when the installation has failed
+	EventReasonInstallFailed = "InstallFailed"
+	// EventReasonUninstalling is used when the uninstallation is waiting for objects to be deleted
+	EventReasonUninstalling = "Uninstalling"
+	// EventReasonUninstalled is used when all objects have been uninstalled
+	EventReasonUninstalled = "Uninstalled"
+	// EventReasonUninstallFailed is used when the uninstallation has failed
+	EventReasonUninstallFailed = "UninstallFailed"
+	// EventReasonVerified is used when all verified objects have become ready
+	EventReasonVerified = "Verified"
+	// EventReasonVerificationFailed is used when the verification has found a failing object
+	EventReasonVerificationFailed = "VerificationFailed"
+)
+
+// readyReleases keeps the last verification result of releases to report only their transitions to ready
+var readyReleases sync.Map
+
+// recordEvent emits the event on the config EventObject if the EventRecorder is configured
+// repeated events are aggregated by the recorder, see the Config.EventRecorder
+func recordEvent(config *Config, eventType, reason, messageFmt string, args ...interface{}) {
+	if config.EventRecorder == nil || config.EventObject == nil {
+		return
+	}
+
+	config.EventRecorder.Eventf(config.EventObject, eventType, reason, messageFmt, args...)
+}
+
+func recordInstallEvent(config *Config, result *InstallResult, err error) {
+	if errors.Is(err, ErrHooksInProgress) {
+		return
+	}
+	if err != nil {
+		recordEvent(config, corev1.EventTypeWarning, EventReasonInstallFailed, "%s", err.Error())
+		return
+	}
+	if !result.Changed() {
+		// nothing to report
+		return
+	}
+
+	recordEvent(config, corev1.EventTypeNormal, EventReasonInstalled, "Applied %d objects (created: %d, updated: %d), pruned %d objects",
+		len(result.Created)+len(result.Updated)+len(result.Unchanged), len(result.Created), len(result.Updated), len(result.Pruned))
+}
+
+func recordUninstallEvent(config *Config, result *UninstallResult, err error) {
+	switch {
+	case err != nil:
+		recordEvent(config, corev1.EventTypeWarning, EventReasonUninstallFailed, "%s", err.Error())
+	case result.Done:
+		recordEvent(config, corev1.EventTypeNormal, EventReasonUninstalled, "Uninstalled all objects")
+	case len(result.Stuck) > 0:
+		stuckErr := &StuckObjectsError{Objects: result.Stuck}
+		recordEvent(config, corev1.EventTypeWarning, EventReasonUninstallFailed, "%s", stuckErr.Error())
+	default:
+		recordEvent(config, corev1.EventTypeNormal, EventReasonUninstalling, "%s", result.Summary())
+	}
+}
+
+func recordVerifyEvent(config *Config, result *VerificationResult, err error) {
+	ready := err == nil && result.Ready
+	previous, _ := readyReleases.Swap(releaseKey(config), ready)
+	wasReady, _ := previous.(bool)
+
+	switch {
+	case err != nil:
+		recordEvent(config, corev1.EventTypeWarning, EventReasonVerificationFailed, "%s", err.Error())
+	case result.Ready:
+		if wasReady {
+			// report only the transition, not every successful verification
+			return
+		}
+		recordEvent(config, corev1.EventTypeNormal, EventReasonVerified, "All verified objects are ready")
+	case result.Reason != "" && result.Reason != DeploymentVerificationProcessing:
+		recordEvent(config, corev1.EventTypeWarning, EventReasonVerificationFailed, "%s", result.Reason)
+	}
+}
+
+func releaseKey(config *Config) string {
+	return fmt.Sprintf("%s/%s/%s", config.ManagerName, config.Release.Namespace, config.Release.Name)
+}
@@ -0,0 +1,167 @@
+package chart
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_recordEvent(t *testing.T) {
+	t.Run("record event", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		config := fixConfig(t, withEventRecorder(recorder))
+
+		recordEvent(config, corev1.EventTypeNormal, "Test", "test %d", 1)
+		require.Equal(t, []string{"Normal Test test 1"}, recordedEventsOf(recorder))
+	})
+
+	t.Run("don't record events without event object", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		config := fixConfig(t, withEventRecorder(recorder))
+		config.EventObject = nil
+
+		recordEvent(config, corev1.EventTypeNormal, "Test", "test")
+		require.Empty(t, recordedEventsOf(recorder))
+	})
+
+	t.Run("don't record events without recorder", func(t *testing.T) {
+		config := fixConfig(t, withEventRecorder(record.NewFakeRecorder(10)))
+		config.EventRecorder = nil
+
+		require.NotPanics(t, func() {
+			recordEvent(config, corev1.EventTypeNormal, "Test", "test")
+		})
+	})
+}
+
+func Test_recordInstallEvent(t *testing.T) {
+	t.Run("record changes", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		config := fixConfig(t, withEventRecorder(recorder))
+
+		recordInstallEvent(config, &InstallResult{
+			Created:   []InstallObject{{Kind: "ServiceAccount"}},
+			Updated:   []InstallObject{{Kind: "Deployment"}},
+			Unchanged: []InstallObject{{Kind: "Service"}},
+			Pruned:    []InstallObject{{Kind: "ConfigMap"}},
+		}, nil)
+		require.Equal(t, []string{"Normal Installed Applied 3 objects (created: 1, updated: 1), pruned 1 objects"}, recordedEventsOf(recorder))
+	})
+
+	t.Run("skip unchanged installation", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		config := fixConfig(t, withEventRecorder(recorder))
+
+		recordInstallEvent(config, &InstallResult{Unchanged: []InstallObject{{Kind: "Service"}}}, nil)
+		require.Empty(t, recordedEventsOf(recorder))
+	})
+
+	t.Run("record failure", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		config := fixConfig(t, withEventRecorder(recorder))
+
+		recordInstallEvent(config, &InstallResult{}, errors.New("test error"))
+		recordInstallEvent(config, &InstallResult{}, ErrHooksInProgress)
+		require.Equal(t, []string{"Warning InstallFailed test error"}, recordedEventsOf(recorder))
+	})
+}
+
+func Test_Uninstall_events(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	deploy := testDeployCR.DeepCopy()
+	deploy.Finalizers = []string{"test/finalizer"}
+	config := fixConfig(t, withEventRecorder(recorder), withCachedManifest(testDeploy), withObjects(deploy))
+
+	for range 2 {
+		_, err := Uninstall(config, &UninstallOpts{})
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{
+		"Normal Uninstalling waiting for stage 'resources': 1 Deployment to be deleted",
+		"Normal Uninstalling waiting for stage 'resources': 1 Deployment to be deleted",
+	}, recordedEventsOf(recorder))
+
+	live := testDeployCR.DeepCopy()
+	require.NoError(t, config.Cluster.Client.Get(context.Background(), types.NamespacedName{Name: "test-deploy", Namespace: "default"}, live))
+	live.Finalizers = nil
+	require.NoError(t, config.Cluster.Client.Update(context.Background(), live))
+
+	done, err := Uninstall(config, &UninstallOpts{})
+	require.NoError(t, err)
+	require.True(t, done)
+	require.Equal(t, []string{"Normal Uninstalled Uninstalled all objects"}, recordedEventsOf(recorder))
+}
+
+func Test_Verify_events(t *testing.T) {
+	t.Run("record failing deployment", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		config := fixConfig(t, withEventRecorder(recorder), withCachedManifest(testDeploy), withObjects(testDeployReplicaFailureCR.DeepCopy()))
+
+		_, err := Verify(config)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Warning VerificationFailed deployment default/test-deploy has replica failure: Replica failure because of test reason"}, recordedEventsOf(recorder))
+	})
+
+	t.Run("don't record processing deployment", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		config := fixConfig(t, withEventRecorder(recorder), withCachedManifest(testDeploy), withObjects(testDeployNotReadyCR.DeepCopy()))
+
+		_, err := Verify(config)
+		require.NoError(t, err)
+		require.Empty(t, recordedEventsOf(recorder))
+	})
+
+	t.Run("record ready deployment", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		config := fixConfig(t, withManagerName(t.Name()), withEventRecorder(recorder), withCachedManifest(testDeploy), withObjects(testDeployCR.DeepCopy()))
+
+		_, err := Verify(config)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Normal Verified All verified objects are ready"}, recordedEventsOf(recorder))
+	})
+
+	t.Run("record only transition to ready", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		config := fixConfig(t, withManagerName(t.Name()), withEventRecorder(recorder), withCachedManifest(testDeploy), withObjects(testDeployCR.DeepCopy()))
+
+		for range 2 {
+			_, err := Verify(config)
+			require.NoError(t, err)
+		}
+		require.Equal(t, []string{"Normal Verified All verified objects are ready"}, recordedEventsOf(recorder))
+
+		notReady := testDeployReplicaFailureCR.DeepCopy()
+		notReady.ResourceVersion = ""
+		require.NoError(t, config.Cluster.Client.Delete(config.Ctx, testDeployCR.DeepCopy()))
+		require.NoError(t, config.Cluster.Client.Create(config.Ctx, notReady))
+		_, err := Verify(config)
+		require.NoError(t, err)
+		require.Len(t, recordedEventsOf(recorder), 1)
+
+		ready := testDeployCR.DeepCopy()
+		ready.ResourceVersion = ""
+		require.NoError(t, config.Cluster.Client.Delete(config.Ctx, notReady))
+		require.NoError(t, config.Cluster.Client.Create(config.Ctx, ready))
+		_, err = Verify(config)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Normal Verified All verified objects are ready"}, recordedEventsOf(recorder))
+	})
+}
+
+// recordedEventsOf drains events emitted to the fake recorder
+func recordedEventsOf(recorder *record.FakeRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
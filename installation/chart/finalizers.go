@@ -0,0 +1,67 @@
+package chart
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StuckObject describes the object pending deletion longer than the finalizer timeout
+type StuckObject struct {
+	Kind              string
+	Namespace         string
+	Name              string
+	Finalizers        []string
+	DeletionTimestamp metav1.Time
+}
+
+// StuckObjectsError is returned by the Uninstall when objects are blocked by finalizers longer than the timeout
+type StuckObjectsError struct {
+	Objects []StuckObject
+}
+
+func (e *StuckObjectsError) Error() string {
+	objs := make([]string, 0, len(e.Objects))
+	for _, obj := range e.Objects {
+		objs = append(objs, fmt.Sprintf("%s %s/%s (finalizers: %s)", obj.Kind, obj.Namespace, obj.Name, strings.Join(obj.Finalizers, ", ")))
+	}
+
+	return fmt.Sprintf("objects are pending deletion longer than expected: %s", strings.Join(objs, "; "))
+}
+
+// isStuck checks if the live object is blocked by finalizers longer than the finalizer timeout
+func isStuck(opts *UninstallOpts, live *unstructured.Unstructured) bool {
+	deletionTimestamp := live.GetDeletionTimestamp()
+	return opts.FinalizerTimeout > 0 &&
+		deletionTimestamp != nil &&
+		len(live.GetFinalizers()) > 0 &&
+		time.Since(deletionTimestamp.Time) >= opts.FinalizerTimeout
+}
+
+func newStuckObject(obj UninstallObject) StuckObject {
+	return StuckObject{
+		Kind:              obj.Kind,
+		Namespace:         obj.Namespace,
+		Name:              obj.Name,
+		Finalizers:        obj.Finalizers,
+		DeletionTimestamp: *obj.DeletionTimestamp,
+	}
+}
+
+func removeFinalizers(config *Config, u *unstructured.Unstructured) error {
+	config.Log.Warnf("removing finalizers %v from %s %s/%s", u.GetFinalizers(), u.GetKind(), u.GetNamespace(), u.GetName())
+
+	// the optimistic lock prevents removing finalizers added or changed since the object has been read
+	patch := client.MergeFromWithOptions(u.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	u.SetFinalizers(nil)
+	err := config.Cluster.Client.Patch(config.Ctx, u, patch)
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("could not remove finalizers from %s %s/%s: %s", u.GetKind(), u.GetNamespace(), u.GetName(), err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package chart
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/manager-toolkit/installation/base/resource"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func fixStuckDeploy(deletedAgo time.Duration) *appsv1.Deployment {
+	deploy := testDeployCR.DeepCopy()
+	deploy.Finalizers = []string{"test/finalizer"}
+	deploy.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-deletedAgo)}
+	return deploy
+}
+
+func Test_Uninstall_stuckFinalizers(t *testing.T) {
+	t.Run("wait for objects before the timeout", func(t *testing.T) {
+		config := fixConfig(t, withCachedManifest(testDeploy), withObjects(fixStuckDeploy(time.Minute)))
+
+		result, err := UninstallWithResult(config, &UninstallOpts{FinalizerTimeout: time.Hour})
+		require.NoError(t, err)
+		require.False(t, result.Done)
+	})
+
+	t.Run("report stuck objects", func(t *testing.T) {
+		config := fixConfig(t, withCachedManifest(testDeploy), withObjects(fixStuckDeploy(2*time.Hour)))
+
+		result, err := UninstallWithResult(config, &UninstallOpts{FinalizerTimeout: time.Hour})
+		require.NoError(t, err)
+		require.False(t, result.Done)
+		require.Len(t, result.Stuck, 1)
+		require.Equal(t, "test-deploy", result.Stuck[0].Name)
+		require.Equal(t, []string{"test/finalizer"}, result.Stuck[0].Finalizers)
+		require.Equal(t, []string{"Deployment"}, uninstallKinds(result.Pending))
+	})
+
+	t.Run("return stuck objects error", func(t *testing.T) {
+		config := fixConfig(t, withCachedManifest(testDeploy), withObjects(fixStuckDeploy(2*time.Hour)))
+
+		done, err := Uninstall(config, &UninstallOpts{FinalizerTimeout: time.Hour})
+		require.False(t, done)
+
+		stuckErr := &StuckObjectsError{}
+		require.True(t, errors.As(err, &stuckErr))
+		require.Len(t, stuckErr.Objects, 1)
+		require.EqualError(t, err, "objects are pending deletion longer than expected: Deployment default/test-deploy (finalizers: test/finalizer)")
+	})
+
+	t.Run("don't report stuck objects without timeout", func(t *testing.T) {
+		config := fixConfig(t, withCachedManifest(testDeploy), withObjects(fixStuckDeploy(2*time.Hour)))
+
+		result, err := UninstallWithResult(config, &UninstallOpts{})
+		require.NoError(t, err)
+		require.False(t, result.Done)
+		require.Empty(t, result.Stuck)
+	})
+
+	t.Run("force finalizers removal", func(t *testing.T) {
+		config := fixConfig(t, withCachedManifest(testDeploy), withObjects(fixStuckDeploy(2*time.Hour)))
+		c := config.Cluster.Client
+		opts := &UninstallOpts{
+			FinalizerTimeout:      time.Hour,
+			ForceRemoveFinalizers: resource.IsDeployment,
+		}
+
+		result, err := UninstallWithResult(config, opts)
+		require.NoError(t, err)
+		require.True(t, result.Done)
+		require.Equal(t, []string{"Deployment"}, uninstallKinds(result.FinalizersRemoved))
+
+		err = c.Get(context.Background(), types.NamespacedName{Name: "test-deploy", Namespace: "default"}, testDeployCR.DeepCopy())
+		require.True(t, k8serrors.IsNotFound(err))
+	})
+
+	t.Run("report stuck objects not matching force removal predicate", func(t *testing.T) {
+		config := fixConfig(t, withCachedManifest(testDeploy), withObjects(fixStuckDeploy(2*time.Hour)))
+
+		_, err := Uninstall(config, &UninstallOpts{
+			FinalizerTimeout:      time.Hour,
+			ForceRemoveFinalizers: resource.IsCRD,
+		})
+		require.ErrorContains(t, err, "Deployment default/test-deploy")
+	})
+}
+
+func Test_removeFinalizers(t *testing.T) {
+	t.Run("don't remove finalizers changed in the meantime", func(t *testing.T) {
+		config := fixConfig(t, withCachedManifest(testDeploy), withObjects(fixStuckDeploy(2*time.Hour)))
+		c := config.Cluster.Client
+		key := types.NamespacedName{Name: "test-deploy", Namespace: "default"}
+
+		stale := &unstructured.Unstructured{}
+		stale.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+		require.NoError(t, c.Get(context.Background(), key, stale))
+
+		live := testDeployCR.DeepCopy()
+		require.NoError(t, c.Get(context.Background(), key, live))
+		live.Finalizers = append(live.Finalizers, "test/other-finalizer")
+		require.NoError(t, c.Update(context.Background(), live))
+
+		err := removeFinalizers(config, stale)
+		require.ErrorContains(t, err, "could not remove finalizers from Deployment default/test-deploy")
+
+		require.NoError(t, c.Get(context.Background(), key, live))
+		require.Equal(t, []string{"test/finalizer", "test/other-finalizer"}, live.Finalizers)
+	})
+}
@@ -2,43 +2,380 @@ package chart
 
 import (
 	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/strvals"
 )
 
+// FlagsBuilder builds Helm chart flags, use NewFlagsBuilder to create it.
+// The interface grows together with new ways of setting flags, so other implementations
+// should embed the FlagsBuilder returned by NewFlagsBuilder to keep compiling after the upgrade.
 type FlagsBuilder interface {
 	Build() (map[string]interface{}, error)
-	With(string, interface{}) *flagsBuilder
+	With(string, interface{}) FlagsBuilder
+	WithIf(bool, string, interface{}) FlagsBuilder
+	WithString(string, string) FlagsBuilder
+	WithList(string, interface{}) FlagsBuilder
+	WithMap(string, interface{}) FlagsBuilder
+	WithRaw(string, string) FlagsBuilder
+	WithValuesFile(string) FlagsBuilder
+	Without(string) FlagsBuilder
+	Merge(FlagsBuilder) FlagsBuilder
+	ValidateWith(*chart.Chart) FlagsBuilder
+}
+
+type flagKind int
+
+const (
+	typedFlag flagKind = iota
+	listFlag
+	mapFlag
+	rawFlag
+)
+
+type flag struct {
+	kind  flagKind
+	value interface{}
 }
 
 // flagsBuilder is used to build Helm chart flags in a structured way.
 type flagsBuilder struct {
-	flags map[string]interface{}
+	flags       map[string]flag
+	valuesFiles []string
+	merged      []FlagsBuilder
+	conflicts   []string
+	invalidKeys []string
+	chart       *chart.Chart
 }
 
 func NewFlagsBuilder() FlagsBuilder {
 	return &flagsBuilder{
-		flags: map[string]interface{}{},
+		flags: map[string]flag{},
 	}
 }
 
 // Build constructs the final map of Helm chart flags.
+// Flags are set in order of sorted keys on top of values from files. Setting both a key and its nested key
+// (for example "a=1" and "a.b=2") is reported as an error.
+// Flags are validated against the chart's values.schema.json if the chart is passed using ValidateWith.
 func (fb *flagsBuilder) Build() (map[string]interface{}, error) {
+	if len(fb.invalidKeys) > 0 {
+		return nil, fmt.Errorf("list indexes are not supported in flag keys, use WithRaw instead: %s", strings.Join(fb.invalidKeys, ", "))
+	}
+	if len(fb.conflicts) > 0 {
+		return nil, fmt.Errorf("flags set multiple times with different values: %s", strings.Join(fb.conflicts, ", "))
+	}
+
+	keys := slices.Sorted(maps.Keys(fb.flags))
+	err := checkFlagKeysConflicts(keys)
+	if err != nil {
+		return nil, err
+	}
+
 	flags := map[string]interface{}{}
-	for key, value := range fb.flags {
-		flag := fmt.Sprintf("%s=%v", key, value)
-		err := strvals.ParseInto(flag, flags)
+	for _, key := range keys {
+		err := setFlag(flags, key, fb.flags[key])
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to parse %s flag", flag)
+			return nil, err
 		}
 	}
+
+	for _, other := range fb.merged {
+		otherFlags, err := other.Build()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to build merged flags")
+		}
+
+		conflicts := conflictingFlagKeys(flags, otherFlags, nil)
+		if len(conflicts) > 0 {
+			return nil, fmt.Errorf("flags set multiple times with different values: %s", strings.Join(conflicts, ", "))
+		}
+		flags = coalesceValues(otherFlags, flags)
+	}
+
+	values := map[string]interface{}{}
+	for _, path := range fb.valuesFiles {
+		fileValues, err := chartutil.ReadValuesFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read values file %s", path)
+		}
+		values = coalesceValues(fileValues, values)
+	}
+	flags = coalesceValues(flags, values)
+
+	if fb.chart != nil {
+		err := validateFlags(fb.chart, flags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return flags, nil
 }
 
-// With adds a new flag to the builder in form of key-value pair.
+// With adds a new flag to the builder in form of key-value pair. String values are parsed the same way
+// as the Helm's --set argument, so "true" or "2" are converted to bool or int, use WithString to keep them as strings.
+// Values of other types are set as they are.
+// Dots in the key separate nested keys, use `\.` to put a dot inside the key. List indexes in the key
+// (for example "a[0]") are reported as an error by Build, use WithRaw or WithList instead.
 // example: With("global.commonLabels.managedBy", "my-manager")
-func (fb *flagsBuilder) With(key string, value interface{}) *flagsBuilder {
-	fb.flags[key] = value
+func (fb *flagsBuilder) With(key string, value interface{}) FlagsBuilder {
+	if s, ok := value.(string); ok {
+		return fb.set(key, flag{kind: rawFlag, value: s})
+	}
+
+	return fb.set(key, flag{kind: typedFlag, value: value})
+}
+
+// WithIf adds a new flag only if the condition is true.
+// example: WithIf(spec.LogLevel != "", "global.logLevel", spec.LogLevel)
+func (fb *flagsBuilder) WithIf(condition bool, key string, value interface{}) FlagsBuilder {
+	if condition {
+		return fb.With(key, value)
+	}
 	return fb
 }
+
+// WithString adds a new string flag without parsing it like the Helm's --set argument,
+// so values like "true", "0123" or "a,b" are kept as they are.
+// example: WithString("podAnnotations.sidecar\.istio\.io/inject", "false")
+func (fb *flagsBuilder) WithString(key string, value string) FlagsBuilder {
+	return fb.set(key, flag{kind: typedFlag, value: value})
+}
+
+// WithList adds a new flag with the list of any type.
+// example: WithList("global.imagePullSecrets", []string{"secret-1", "secret-2"})
+func (fb *flagsBuilder) WithList(key string, list interface{}) FlagsBuilder {
+	return fb.set(key, flag{kind: listFlag, value: list})
+}
+
+// WithMap adds a new flag with the map with string keys.
+// example: WithMap("global.commonLabels", map[string]string{"app": "my-app"})
+func (fb *flagsBuilder) WithMap(key string, m interface{}) FlagsBuilder {
+	return fb.set(key, flag{kind: mapFlag, value: m})
+}
+
+// WithRaw adds a new flag parsed the same way as the Helm's --set argument, so the value type is guessed.
+// example: WithRaw("global.images", "{image-1,image-2}")
+func (fb *flagsBuilder) WithRaw(key string, value string) FlagsBuilder {
+	fb.flags[key] = flag{kind: rawFlag, value: value}
+	return fb
+}
+
+// WithValuesFile adds values from the YAML file. Values from files are overridden by all other flags
+// and following files override previous ones.
+func (fb *flagsBuilder) WithValuesFile(path string) FlagsBuilder {
+	fb.valuesFiles = append(fb.valuesFiles, path)
+	return fb
+}
+
+// Without removes the flag and all flags nested under it.
+// example: Without("global.images") removes both "global.images" and "global.images.rp" flags
+func (fb *flagsBuilder) Without(key string) FlagsBuilder {
+	path := splitFlagKey(key)
+	for flagKey := range fb.flags {
+		if isPathPrefix(path, splitFlagKey(flagKey)) {
+			delete(fb.flags, flagKey)
+		}
+	}
+	return fb
+}
+
+// Merge adds all flags and values files from the other builder. Setting the same flag with different values
+// in both builders is reported as an error by Build. Flags of other FlagsBuilder implementations are built
+// and compared with flags of this builder during the Build.
+func (fb *flagsBuilder) Merge(other FlagsBuilder) FlagsBuilder {
+	otherBuilder, ok := other.(*flagsBuilder)
+	if !ok {
+		// flags from other implementations are merged during the build
+		fb.merged = append(fb.merged, other)
+		return fb
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(otherBuilder.flags)) {
+		otherFlag := otherBuilder.flags[key]
+		if current, exists := fb.flags[key]; exists && !reflect.DeepEqual(current, otherFlag) {
+			fb.conflicts = append(fb.conflicts, key)
+		}
+		fb.flags[key] = otherFlag
+	}
+	fb.valuesFiles = append(fb.valuesFiles, otherBuilder.valuesFiles...)
+	fb.merged = append(fb.merged, otherBuilder.merged...)
+	fb.conflicts = append(fb.conflicts, otherBuilder.conflicts...)
+	fb.invalidKeys = append(fb.invalidKeys, otherBuilder.invalidKeys...)
+	return fb
+}
+
+// ValidateWith enables validation of built flags merged with the chart's default values against the chart's values.schema.json.
+func (fb *flagsBuilder) ValidateWith(chart *chart.Chart) FlagsBuilder {
+	fb.chart = chart
+	return fb
+}
+
+// set adds the flag, keys with list indexes are reported by the Build as they can't be split into nested keys
+func (fb *flagsBuilder) set(key string, f flag) FlagsBuilder {
+	if strings.ContainsAny(key, "[]") {
+		fb.invalidKeys = append(fb.invalidKeys, key)
+		return fb
+	}
+
+	fb.flags[key] = f
+	return fb
+}
+
+// checkFlagKeysConflicts returns error if any key is nested under other key, e.g. "a" and "a.b"
+func checkFlagKeysConflicts(keys []string) error {
+	paths := make(map[string]string, len(keys))
+	for _, key := range keys {
+		paths[strings.Join(splitFlagKey(key), "\x00")] = key
+	}
+
+	for _, key := range keys {
+		path := splitFlagKey(key)
+		for i := 1; i < len(path); i++ {
+			if parentKey, exists := paths[strings.Join(path[:i], "\x00")]; exists {
+				return fmt.Errorf("flag %s conflicts with flag %s", key, parentKey)
+			}
+		}
+	}
+
+	return nil
+}
+
+// conflictingFlagKeys returns sorted keys set in both flags with different values
+func conflictingFlagKeys(flags, otherFlags map[string]interface{}, path []string) []string {
+	conflicts := []string{}
+	for _, key := range slices.Sorted(maps.Keys(otherFlags)) {
+		value, exists := flags[key]
+		if !exists {
+			continue
+		}
+
+		keyPath := append(slices.Clone(path), key)
+		valueMap, isMap := value.(map[string]interface{})
+		otherValueMap, isOtherMap := otherFlags[key].(map[string]interface{})
+		if isMap && isOtherMap {
+			conflicts = append(conflicts, conflictingFlagKeys(valueMap, otherValueMap, keyPath)...)
+			continue
+		}
+
+		if !reflect.DeepEqual(value, otherFlags[key]) {
+			conflicts = append(conflicts, joinFlagKey(keyPath))
+		}
+	}
+
+	return conflicts
+}
+
+// joinFlagKey joins the path with dots, dots inside keys are escaped
+func joinFlagKey(path []string) string {
+	keys := make([]string, len(path))
+	for i := range path {
+		keys[i] = strings.ReplaceAll(path[i], ".", `\.`)
+	}
+
+	return strings.Join(keys, ".")
+}
+
+func isPathPrefix(prefix, path []string) bool {
+	return len(prefix) <= len(path) && slices.Equal(prefix, path[:len(prefix)])
+}
+
+func setFlag(flags map[string]interface{}, key string, f flag) error {
+	if f.kind == rawFlag {
+		flagString := fmt.Sprintf("%s=%v", key, f.value)
+		err := strvals.ParseInto(flagString, flags)
+		return errors.Wrapf(err, "failed to parse %s flag", flagString)
+	}
+
+	value, err := normalizeFlagValue(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set %s flag", key)
+	}
+
+	return errors.Wrapf(setNestedValue(flags, splitFlagKey(key), value), "failed to set %s flag", key)
+}
+
+func normalizeFlagValue(f flag) (interface{}, error) {
+	value := reflect.ValueOf(f.value)
+	switch f.kind {
+	case listFlag:
+		if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+			return nil, fmt.Errorf("expected list, got %T", f.value)
+		}
+
+		list := make([]interface{}, value.Len())
+		for i := range list {
+			list[i] = value.Index(i).Interface()
+		}
+		return deepCopyValue(list), nil
+	case mapFlag:
+		if value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("expected map with string keys, got %T", f.value)
+		}
+
+		m := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = iter.Value().Interface()
+		}
+		return deepCopyValue(m), nil
+	default:
+		return deepCopyValue(f.value), nil
+	}
+}
+
+// splitFlagKey splits the key by dots, escaped dots (`\.`) are part of the key
+func splitFlagKey(key string) []string {
+	path := []string{}
+	current := strings.Builder{}
+	for i := 0; i < len(key); i++ {
+		switch {
+		case key[i] == '\\' && i+1 < len(key) && key[i+1] == '.':
+			current.WriteByte('.')
+			i++
+		case key[i] == '.':
+			path = append(path, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(key[i])
+		}
+	}
+
+	return append(path, current.String())
+}
+
+func setNestedValue(flags map[string]interface{}, path []string, value interface{}) error {
+	current := flags
+	for i, key := range path[:len(path)-1] {
+		next, exists := current[key]
+		if !exists {
+			next = map[string]interface{}{}
+			current[key] = next
+		}
+
+		nextMap, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s is not a map", strings.Join(path[:i+1], "."))
+		}
+		current = nextMap
+	}
+
+	current[path[len(path)-1]] = value
+	return nil
+}
+
+func validateFlags(chart *chart.Chart, flags map[string]interface{}) error {
+	values, err := chartutil.CoalesceValues(chart, flags)
+	if err != nil {
+		return errors.Wrap(err, "failed to merge flags with chart values")
+	}
+
+	return errors.Wrap(chartutil.ValidateAgainstSchema(chart, values), "flags do not match the chart values schema")
+}
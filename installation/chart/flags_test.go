@@ -1,6 +1,9 @@
 package chart
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -43,4 +46,260 @@ func Test_flagsBuilder_Build(t *testing.T) {
 		require.Equal(t, expectedFlags, flags)
 	})
 
+	t.Run("keep value types", func(t *testing.T) {
+		expectedFlags := map[string]interface{}{
+			"replicas": 2,
+			"enabled":  "true",
+			"version":  "0123",
+			"args":     "--a=b,--c=d",
+			"podAnnotations": map[string]interface{}{
+				"sidecar.istio.io/inject": "false",
+			},
+		}
+
+		flags, err := NewFlagsBuilder().
+			With("replicas", 2).
+			WithString("enabled", "true").
+			WithString("version", "0123").
+			WithString("args", "--a=b,--c=d").
+			WithString(`podAnnotations.sidecar\.istio\.io/inject`, "false").
+			Build()
+
+		require.NoError(t, err)
+		require.Equal(t, expectedFlags, flags)
+	})
+
+	t.Run("parse string values like --set", func(t *testing.T) {
+		expectedFlags := map[string]interface{}{
+			"enabled":  false,
+			"replicas": int64(3),
+			"podAnnotations": map[string]interface{}{
+				"sidecar.istio.io/inject": "x",
+			},
+		}
+
+		flags, err := NewFlagsBuilder().
+			With("enabled", "false").
+			With("replicas", "3").
+			With(`podAnnotations.sidecar\.istio\.io/inject`, "x").
+			Build()
+
+		require.NoError(t, err)
+		require.Equal(t, expectedFlags, flags)
+	})
+
+	t.Run("list index in key", func(t *testing.T) {
+		_, err := NewFlagsBuilder().
+			With("args[0]", "--a").
+			Merge(NewFlagsBuilder().WithString("env[1].name", "b")).
+			Build()
+
+		require.EqualError(t, err, "list indexes are not supported in flag keys, use WithRaw instead: args[0], env[1].name")
+	})
+
+	t.Run("build lists and maps", func(t *testing.T) {
+		labels := map[string]string{"app": "test"}
+		expectedFlags := map[string]interface{}{
+			"global": map[string]interface{}{
+				"imagePullSecrets": []interface{}{"secret-1", "secret-2"},
+				"commonLabels": map[string]interface{}{
+					"app": "test",
+				},
+			},
+		}
+
+		flags, err := NewFlagsBuilder().
+			WithList("global.imagePullSecrets", []string{"secret-1", "secret-2"}).
+			WithMap("global.commonLabels", labels).
+			Build()
+
+		require.NoError(t, err)
+		require.Equal(t, expectedFlags, flags)
+	})
+
+	t.Run("build raw flags", func(t *testing.T) {
+		expectedFlags := map[string]interface{}{
+			"enabled": true,
+			"images":  []interface{}{"image-1", "image-2"},
+		}
+
+		flags, err := NewFlagsBuilder().
+			WithRaw("enabled", "true").
+			WithRaw("images", "{image-1,image-2}").
+			Build()
+
+		require.NoError(t, err)
+		require.Equal(t, expectedFlags, flags)
+	})
+
+	t.Run("wrong list type", func(t *testing.T) {
+		_, err := NewFlagsBuilder().WithList("images", "image-1").Build()
+		require.ErrorContains(t, err, "failed to set images flag: expected list, got string")
+	})
+
+	t.Run("wrong map type", func(t *testing.T) {
+		_, err := NewFlagsBuilder().WithMap("labels", map[int]string{1: "one"}).Build()
+		require.ErrorContains(t, err, "failed to set labels flag: expected map with string keys")
+	})
+
+	t.Run("validate flags against schema", func(t *testing.T) {
+		ch, err := NewDirChartSource("testdata/test-chart").Load(context.Background())
+		require.NoError(t, err)
+
+		flags, err := NewFlagsBuilder().With("replicas", 3).ValidateWith(ch).Build()
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"replicas": 3}, flags)
+
+		_, err = NewFlagsBuilder().WithString("replicas", "3").ValidateWith(ch).Build()
+		require.ErrorContains(t, err, "flags do not match the chart values schema")
+	})
+}
+
+func Test_flagsBuilder_Compose(t *testing.T) {
+	t.Run("add flags conditionally", func(t *testing.T) {
+		flags, err := NewFlagsBuilder().
+			WithIf(true, "enabled", true).
+			WithIf(false, "disabled", true).
+			Build()
+
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"enabled": true}, flags)
+	})
+
+	t.Run("remove flags", func(t *testing.T) {
+		flags, err := NewFlagsBuilder().
+			With("global.images.rp", "rp-im").
+			With("global.images.connection", "conn-im").
+			With("global.imagesPullPolicy", "Always").
+			Without("global.images").
+			Build()
+
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"global": map[string]interface{}{"imagesPullPolicy": "Always"},
+		}, flags)
+	})
+
+	t.Run("merge builders", func(t *testing.T) {
+		flags, err := NewFlagsBuilder().
+			With("global.images.rp", "rp-im").
+			Merge(NewFlagsBuilder().With("global.images.connection", "conn-im").With("global.images.rp", "rp-im")).
+			Build()
+
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"global": map[string]interface{}{
+				"images": map[string]interface{}{
+					"rp":         "rp-im",
+					"connection": "conn-im",
+				},
+			},
+		}, flags)
+	})
+
+	t.Run("merge other builder implementation", func(t *testing.T) {
+		flags, err := NewFlagsBuilder().
+			With("replicas", 1).
+			With("global.images.rp", "rp-im").
+			Merge(&staticFlagsBuilder{flags: map[string]interface{}{
+				"replicas": 1,
+				"image":    "test",
+				"global":   map[string]interface{}{"images": map[string]interface{}{"connection": "conn-im"}},
+			}}).
+			Build()
+
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"replicas": 1,
+			"image":    "test",
+			"global": map[string]interface{}{
+				"images": map[string]interface{}{
+					"rp":         "rp-im",
+					"connection": "conn-im",
+				},
+			},
+		}, flags)
+	})
+
+	t.Run("merge conflict with other builder implementation", func(t *testing.T) {
+		_, err := NewFlagsBuilder().
+			With("replicas", 1).
+			With(`podAnnotations.sidecar\.istio\.io/inject`, "false").
+			Merge(&staticFlagsBuilder{flags: map[string]interface{}{
+				"replicas":       2,
+				"podAnnotations": map[string]interface{}{"sidecar.istio.io/inject": "true"},
+			}}).
+			Build()
+
+		require.ErrorContains(t, err, `flags set multiple times with different values: podAnnotations.sidecar\.istio\.io/inject, replicas`)
+	})
+
+	t.Run("merge conflict", func(t *testing.T) {
+		_, err := NewFlagsBuilder().
+			With("global.images.rp", "rp-im").
+			Merge(NewFlagsBuilder().With("global.images.rp", "other-im")).
+			Build()
+
+		require.ErrorContains(t, err, "flags set multiple times with different values: global.images.rp")
+	})
+
+	t.Run("nested key conflict", func(t *testing.T) {
+		_, err := NewFlagsBuilder().
+			With("a", 1).
+			With("a.b", 2).
+			Build()
+
+		require.ErrorContains(t, err, "flag a.b conflicts with flag a")
+	})
+
+	t.Run("escaped key does not conflict", func(t *testing.T) {
+		flags, err := NewFlagsBuilder().
+			With("a", 1).
+			With(`a\.b`, 2).
+			Build()
+
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"a": 1, "a.b": 2}, flags)
+	})
+
+	t.Run("values files", func(t *testing.T) {
+		dir := t.TempDir()
+		firstPath := filepath.Join(dir, "first.yaml")
+		secondPath := filepath.Join(dir, "second.yaml")
+		require.NoError(t, os.WriteFile(firstPath, []byte("replicas: 1\nimage: first\nlogLevel: info\n"), 0o600))
+		require.NoError(t, os.WriteFile(secondPath, []byte("image: second\n"), 0o600))
+
+		flags, err := NewFlagsBuilder().
+			WithValuesFile(firstPath).
+			WithValuesFile(secondPath).
+			With("replicas", 3).
+			Build()
+
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"replicas": 3, "image": "second", "logLevel": "info"}, flags)
+	})
+
+	t.Run("values file not found", func(t *testing.T) {
+		_, err := NewFlagsBuilder().
+			WithValuesFile(filepath.Join(t.TempDir(), "not-existing.yaml")).
+			Build()
+
+		require.ErrorContains(t, err, "failed to read values file")
+	})
+}
+
+type staticFlagsBuilder struct {
+	FlagsBuilder
+	flags map[string]interface{}
+}
+
+func (fb *staticFlagsBuilder) Build() (map[string]interface{}, error) {
+	return fb.flags, nil
+}
+
+func Test_splitFlagKey(t *testing.T) {
+	require.Equal(t, []string{"a"}, splitFlagKey("a"))
+	require.Equal(t, []string{"a", "b", "c"}, splitFlagKey("a.b.c"))
+	require.Equal(t, []string{"a", "b.c"}, splitFlagKey(`a.b\.c`))
+	require.Equal(t, []string{`a\b`}, splitFlagKey(`a\b`))
 }
@@ -0,0 +1,89 @@
+package chart
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// isSpecChanged returns true if the current spec should be deployed as a new revision
+// rendering the same spec again (for example with ForceRender) doesn't create a new revision
+func isSpecChanged(cachedSpec, currentSpec ContextManifest) bool {
+	return shouldRenderAgain(cachedSpec, currentSpec) ||
+		cachedSpec.Manifest != currentSpec.Manifest
+}
+
+// withDeployedRevision returns the cached spec with the deployed one as the current revision
+// the previously deployed revision is moved to the history as superseded
+func withDeployedRevision(config *Config, cachedSpec ContextManifest, deployed ContextManifest) ContextManifest {
+	deployed.Revision = lastRevisionNumber(cachedSpec) + 1
+	deployed.Timestamp = metav1.Now()
+	deployed.History = append([]ManifestRevision{}, cachedSpec.History...)
+
+	if cachedSpec.Revision != 0 || cachedSpec.Manifest != "" {
+		superseded := cachedSpec.currentRevision()
+		superseded.Outcome = RevisionSuperseded
+		deployed.History = append(deployed.History, superseded)
+	}
+
+	deployed.History = trimHistory(deployed.History, config.MaxHistory)
+	return deployed
+}
+
+// withFailedRevision returns the cached spec with the failed one appended to the history
+// the currently deployed revision stays untouched
+func withFailedRevision(config *Config, cachedSpec ContextManifest, failed ContextManifest) ContextManifest {
+	history := append([]ManifestRevision{}, cachedSpec.History...)
+	revision := ManifestRevision{
+		Revision:           lastRevisionNumber(cachedSpec) + 1,
+		ManagerUID:         failed.ManagerUID,
+		CustomFlags:        failed.CustomFlags,
+		Values:             failed.Values,
+		Manifest:           failed.Manifest,
+		ChartDigest:        failed.ChartDigest,
+		PostRendererDigest: failed.PostRendererDigest,
+		Subcharts:          failed.Subcharts,
+		Timestamp:          metav1.Now(),
+		Outcome:            RevisionFailed,
+	}
+
+	// don't flood the history with the same revision failing on every reconciliation
+	if last := len(history) - 1; last >= 0 && isSameRevision(history[last], revision) {
+		revision.Revision = history[last].Revision
+		history = history[:last]
+	}
+
+	cachedSpec.History = trimHistory(append(history, revision), config.MaxHistory)
+	return cachedSpec
+}
+
+func isSameRevision(previous, current ManifestRevision) bool {
+	return previous.Outcome == current.Outcome &&
+		previous.ManagerUID == current.ManagerUID &&
+		previous.Manifest == current.Manifest &&
+		previous.ChartDigest == current.ChartDigest &&
+		previous.PostRendererDigest == current.PostRendererDigest &&
+		equalFlags(previous.CustomFlags, current.CustomFlags) &&
+		equalValues(previous.Values, current.Values)
+}
+
+func lastRevisionNumber(spec ContextManifest) int {
+	last := spec.Revision
+	for _, revision := range spec.History {
+		if revision.Revision > last {
+			last = revision.Revision
+		}
+	}
+
+	return last
+}
+
+func trimHistory(history []ManifestRevision, maxHistory int) []ManifestRevision {
+	if maxHistory <= 0 {
+		return nil
+	}
+
+	if len(history) > maxHistory {
+		return history[len(history)-maxHistory:]
+	}
+
+	return history
+}
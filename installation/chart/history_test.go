@@ -0,0 +1,126 @@
+package chart
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_withDeployedRevision(t *testing.T) {
+	t.Run("first revision", func(t *testing.T) {
+		spec := withDeployedRevision(&Config{MaxHistory: 3}, emptyContextManifest, ContextManifest{
+			Manifest: testDeploy,
+		})
+
+		require.Equal(t, 1, spec.Revision)
+		require.Equal(t, testDeploy, spec.Manifest)
+		require.False(t, spec.Timestamp.IsZero())
+		require.Empty(t, spec.History)
+	})
+
+	t.Run("supersede previous revision", func(t *testing.T) {
+		cachedSpec := ContextManifest{
+			ManagerUID: "uid-1",
+			Manifest:   testCRD,
+			Revision:   1,
+		}
+
+		spec := withDeployedRevision(&Config{MaxHistory: 3}, cachedSpec, ContextManifest{
+			ManagerUID: "uid-2",
+			Manifest:   testDeploy,
+		})
+
+		require.Equal(t, 2, spec.Revision)
+		require.Equal(t, []ManifestRevision{
+			{Revision: 1, ManagerUID: "uid-1", Manifest: testCRD, Outcome: RevisionSuperseded},
+		}, spec.History)
+	})
+
+	t.Run("keep only last revisions", func(t *testing.T) {
+		cachedSpec := ContextManifest{
+			Manifest: testDeploy,
+			Revision: 4,
+			History: []ManifestRevision{
+				{Revision: 1, Outcome: RevisionSuperseded},
+				{Revision: 2, Outcome: RevisionSuperseded},
+				{Revision: 3, Outcome: RevisionFailed},
+			},
+		}
+
+		spec := withDeployedRevision(&Config{MaxHistory: 2}, cachedSpec, ContextManifest{})
+
+		require.Equal(t, 5, spec.Revision)
+		require.Len(t, spec.History, 2)
+		require.Equal(t, 3, spec.History[0].Revision)
+		require.Equal(t, 4, spec.History[1].Revision)
+	})
+
+	t.Run("history disabled", func(t *testing.T) {
+		cachedSpec := ContextManifest{
+			Manifest: testDeploy,
+			Revision: 1,
+		}
+
+		spec := withDeployedRevision(&Config{}, cachedSpec, ContextManifest{})
+
+		require.Equal(t, 2, spec.Revision)
+		require.Empty(t, spec.History)
+	})
+}
+
+func Test_withFailedRevision(t *testing.T) {
+	t.Run("append failed revision", func(t *testing.T) {
+		cachedSpec := ContextManifest{
+			Manifest: testCRD,
+			Revision: 1,
+		}
+
+		spec := withFailedRevision(&Config{MaxHistory: 3}, cachedSpec, ContextManifest{
+			Manifest: testDeploy,
+		})
+
+		require.Equal(t, 1, spec.Revision)
+		require.Equal(t, testCRD, spec.Manifest)
+		require.Len(t, spec.History, 1)
+		require.Equal(t, 2, spec.History[0].Revision)
+		require.Equal(t, RevisionFailed, spec.History[0].Outcome)
+		require.Equal(t, testDeploy, spec.History[0].Manifest)
+	})
+
+	t.Run("replace the same failed revision", func(t *testing.T) {
+		cachedSpec := ContextManifest{
+			Manifest: testCRD,
+			Revision: 1,
+		}
+		failedSpec := ContextManifest{
+			Manifest: testDeploy,
+		}
+
+		spec := withFailedRevision(&Config{MaxHistory: 3}, cachedSpec, failedSpec)
+		spec = withFailedRevision(&Config{MaxHistory: 3}, spec, failedSpec)
+
+		require.Len(t, spec.History, 1)
+		require.Equal(t, 2, spec.History[0].Revision)
+	})
+}
+
+func TestContextManifest_Revisions(t *testing.T) {
+	t.Run("no revisions", func(t *testing.T) {
+		require.Empty(t, emptyContextManifest.Revisions())
+	})
+
+	t.Run("history with current revision", func(t *testing.T) {
+		spec := ContextManifest{
+			Manifest: testDeploy,
+			Revision: 2,
+			History: []ManifestRevision{
+				{Revision: 1, Manifest: testCRD, Outcome: RevisionSuperseded},
+			},
+		}
+
+		require.Equal(t, []ManifestRevision{
+			{Revision: 1, Manifest: testCRD, Outcome: RevisionSuperseded},
+			{Revision: 2, Manifest: testDeploy, Outcome: RevisionDeployed},
+		}, spec.Revisions())
+	})
+}
@@ -0,0 +1,309 @@
+package chart
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kyma-project/manager-toolkit/installation/base/resource"
+
+	"helm.sh/helm/v3/pkg/release"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrHooksInProgress is returned by the Install when chart hooks are not completed yet
+// the installation should be repeated later to continue
+var ErrHooksInProgress = errors.New("chart hooks are in progress")
+
+const hookRunAnnotationFormat = "%s.kyma-project.io/hook-run"
+
+// hookRunAnnotation returns the annotation key marking hook objects created by the manager
+func hookRunAnnotation(managerName string) (string, error) {
+	key := fmt.Sprintf(hookRunAnnotationFormat, managerName)
+	if errs := validation.IsQualifiedName(key); managerName == "" || len(errs) > 0 {
+		return "", fmt.Errorf("manager name '%s' can't be used to annotate hook objects: %s", managerName, strings.Join(errs, ", "))
+	}
+
+	return key, nil
+}
+
+// pendingInstallHooks returns post install and post upgrade events which have to be completed after the installation
+// other events (e.g. post delete of the aborted uninstallation) are not run by the installation
+func pendingInstallHooks(cachedSpec ContextManifest) release.HookEvent {
+	switch cachedSpec.PendingHooks {
+	case release.HookPostInstall, release.HookPostUpgrade:
+		return cachedSpec.PendingHooks
+	default:
+		return ""
+	}
+}
+
+// installHookEvents returns pre and post hook events for the installation of the current spec
+func installHookEvents(cachedSpec ContextManifest) (release.HookEvent, release.HookEvent) {
+	if cachedSpec.Revision == 0 && cachedSpec.Manifest == "" {
+		// nothing is deployed yet
+		return release.HookPreInstall, release.HookPostInstall
+	}
+
+	return release.HookPreUpgrade, release.HookPostUpgrade
+}
+
+// shouldRunInstallHooks returns true if the manifest, values or hooks have changed since the last installation
+// changes of the manager or the chart digest alone don't run hooks again
+func shouldRunInstallHooks(cachedSpec, currentSpec ContextManifest) bool {
+	return cachedSpec.Manifest != currentSpec.Manifest ||
+		!equalValues(cachedSpec.Values, currentSpec.Values) ||
+		!equalHooks(cachedSpec.Hooks, currentSpec.Hooks)
+}
+
+func equalHooks(hooks, otherHooks []*release.Hook) bool {
+	if len(hooks) != len(otherHooks) {
+		return false
+	}
+
+	for i := range hooks {
+		if hooks[i].Path != otherHooks[i].Path || hooks[i].Manifest != otherHooks[i].Manifest {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pendingHookEvent returns the event if there are hooks to run for it or an empty event otherwise
+func pendingHookEvent(hooks []*release.Hook, event release.HookEvent) release.HookEvent {
+	if len(hooksForEvent(hooks, event)) == 0 {
+		return ""
+	}
+
+	return event
+}
+
+// runHooks executes hooks of the given event one by one in order of their weights
+// it returns false if any of them is still in progress
+func runHooks(config *Config, spec ContextManifest, event release.HookEvent) (bool, error) {
+	hooks := hooksForEvent(spec.Hooks, event)
+	runID := hookRunID(spec, event)
+
+	for _, hook := range hooks {
+		done, err := runHook(config, hook, runID)
+		if err != nil {
+			return false, fmt.Errorf("while running %s hook '%s': %s", event, hook.Name, err.Error())
+		}
+
+		if !done {
+			config.Log.Debugf("waiting for %s hook '%s'", event, hook.Name)
+			return false, nil
+		}
+	}
+
+	// all hooks succeeded
+	for _, hook := range hooks {
+		if !hasDeletePolicy(hook, release.HookSucceeded) {
+			continue
+		}
+
+		_, err := deleteHookObjects(config, hook)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func runHook(config *Config, hook *release.Hook, runID string) (bool, error) {
+	objs, err := parseManifest(hook.Manifest)
+	if err != nil {
+		return false, fmt.Errorf("could not parse hook manifest: %s", err.Error())
+	}
+
+	done := true
+	for i := range objs {
+		objDone, err := runHookObject(config, hook, objs[i], runID)
+		if err != nil {
+			return false, err
+		}
+
+		if !objDone {
+			done = false
+		}
+	}
+
+	return done, nil
+}
+
+func runHookObject(config *Config, hook *release.Hook, u unstructured.Unstructured, runID string) (bool, error) {
+	runAnnotation, err := hookRunAnnotation(config.ManagerName)
+	if err != nil {
+		return false, err
+	}
+
+	live := unstructured.Unstructured{}
+	live.SetGroupVersionKind(u.GroupVersionKind())
+	err = config.Cluster.Client.Get(config.Ctx, client.ObjectKeyFromObject(&u), &live)
+	if client.IgnoreNotFound(err) != nil {
+		return false, fmt.Errorf("could not get hook object %s/%s: %s", u.GetNamespace(), u.GetName(), err.Error())
+	}
+
+	if k8serrors.IsNotFound(err) {
+		return createHookObject(config, u, runAnnotation, runID)
+	}
+
+	if live.GetAnnotations()[runAnnotation] != runID {
+		// object left by the previous run
+		if live.GetDeletionTimestamp() != nil {
+			return false, nil
+		}
+
+		if !hasDeletePolicy(hook, release.HookBeforeHookCreation) {
+			return createHookObject(config, u, runAnnotation, runID)
+		}
+
+		_, err = resource.Delete(config.Ctx, config.Cluster.Client, config.Log, live)
+		return false, err
+	}
+
+	completed, failed := hookObjectStatus(live)
+	if failed {
+		if hasDeletePolicy(hook, release.HookFailed) {
+			_, err = resource.Delete(config.Ctx, config.Cluster.Client, config.Log, live)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		return false, fmt.Errorf("%s %s/%s failed", live.GetKind(), live.GetNamespace(), live.GetName())
+	}
+
+	return completed, nil
+}
+
+func createHookObject(config *Config, u unstructured.Unstructured, runAnnotation, runID string) (bool, error) {
+	annotations := u.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[runAnnotation] = runID
+	u.SetAnnotations(annotations)
+
+	config.Log.Debugf("creating hook %s %s/%s", u.GetKind(), u.GetNamespace(), u.GetName())
+	err := config.Cluster.Client.Apply(config.Ctx, client.ApplyConfigurationFromUnstructured(&u), &client.ApplyOptions{
+		Force:        ptr.To(true),
+		FieldManager: config.ManagerName,
+	})
+	if err != nil {
+		return false, fmt.Errorf("could not create hook object %s/%s: %s", u.GetNamespace(), u.GetName(), err.Error())
+	}
+
+	completed, _ := hookObjectStatus(u)
+	return completed, nil
+}
+
+// hookObjectStatus returns if the hook object has completed or failed
+// only Jobs and Pods are awaited, other objects are completed once they exist
+func hookObjectStatus(u unstructured.Unstructured) (bool, bool) {
+	switch u.GroupVersionKind().GroupKind().String() {
+	case "Job.batch":
+		conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["status"] != "True" {
+				continue
+			}
+
+			switch condition["type"] {
+			case "Complete":
+				return true, false
+			case "Failed":
+				return false, true
+			}
+		}
+
+		return false, false
+	case "Pod":
+		phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+		return phase == "Succeeded", phase == "Failed"
+	default:
+		return true, false
+	}
+}
+
+func deleteHookObjects(config *Config, hook *release.Hook) (bool, error) {
+	objs, err := parseManifest(hook.Manifest)
+	if err != nil {
+		return false, fmt.Errorf("could not parse hook manifest: %s", err.Error())
+	}
+
+	return deleteObjects(config, objs)
+}
+
+func hooksForEvent(hooks []*release.Hook, event release.HookEvent) []*release.Hook {
+	result := []*release.Hook{}
+	for _, hook := range hooks {
+		for _, e := range hook.Events {
+			if e == event {
+				result = append(result, hook)
+				break
+			}
+		}
+	}
+
+	// the same order as in the helm
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Weight == result[j].Weight {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Weight < result[j].Weight
+	})
+
+	return result
+}
+
+func hasDeletePolicy(hook *release.Hook, policy release.HookDeletePolicy) bool {
+	if len(hook.DeletePolicies) == 0 {
+		// helm's default policy
+		return policy == release.HookBeforeHookCreation
+	}
+
+	for _, p := range hook.DeletePolicies {
+		if p == policy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hookRunID identifies hooks run for the given manifest, values and event
+// hooks are executed again only when any of them changes
+func hookRunID(spec ContextManifest, event release.HookEvent) string {
+	h := sha256.New()
+	h.Write([]byte(spec.Manifest))
+	for _, hook := range spec.Hooks {
+		h.Write([]byte(hook.Manifest))
+	}
+	// values are marshalled with sorted keys so the same values always give the same id
+	values, _ := json.Marshal(spec.Values)
+	h.Write(values)
+
+	return fmt.Sprintf("%s-%x", event, h.Sum(nil)[:8])
+}
+
+// RunTests runs test hooks of the chart installed in the cluster
+// tests are executed once for every installed manifest, it returns false if they are still in progress
+func RunTests(config *Config) (bool, error) {
+	spec, err := config.Cache.Get(config.Ctx, config.CacheKey)
+	if err != nil {
+		return false, fmt.Errorf("could not get manifest from cache: %s", err.Error())
+	}
+
+	return runHooks(config, spec, release.HookTest)
+}
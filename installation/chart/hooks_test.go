@@ -0,0 +1,393 @@
+package chart
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"helm.sh/helm/v3/pkg/release"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testHookJob = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: test-hook-job
+  namespace: default
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: migrate
+        image: migrate:1.0
+`
+	testHookConfigMap = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-hook-cm
+  namespace: default
+`
+)
+
+var testHookKey = types.NamespacedName{Name: "test", Namespace: "testnamespace"}
+
+func fixHookConfig(c client.Client) *Config {
+	return &Config{
+		Ctx:         context.Background(),
+		Log:         zap.NewNop().Sugar(),
+		Cache:       NewInMemoryManifestCache(),
+		CacheKey:    testHookKey,
+		ManagerUID:  "test-uid",
+		ManagerName: "test-manager",
+		Cluster: Cluster{
+			Client: c,
+		},
+	}
+}
+
+func fixHook(name, manifest string, weight int, events []release.HookEvent, policies ...release.HookDeletePolicy) *release.Hook {
+	return &release.Hook{
+		Name:           name,
+		Kind:           "Job",
+		Manifest:       manifest,
+		Weight:         weight,
+		Events:         events,
+		DeletePolicies: policies,
+	}
+}
+
+func setJobCondition(t *testing.T, c client.Client, conditionType batchv1.JobConditionType) {
+	job := &batchv1.Job{}
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, job))
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:   conditionType,
+		Status: corev1.ConditionTrue,
+	})
+	require.NoError(t, c.Status().Update(context.Background(), job))
+}
+
+func Test_runHooks(t *testing.T) {
+	t.Run("wait for job and delete it when succeeded", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		config := fixHookConfig(c)
+		spec := ContextManifest{
+			Hooks: []*release.Hook{
+				fixHook("job", testHookJob, 0, []release.HookEvent{release.HookPreInstall}, release.HookSucceeded),
+			},
+		}
+
+		done, err := runHooks(config, spec, release.HookPreInstall)
+		require.NoError(t, err)
+		require.False(t, done)
+
+		job := &batchv1.Job{}
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, job))
+		require.Equal(t, hookRunID(spec, release.HookPreInstall), job.GetAnnotations()["test-manager.kyma-project.io/hook-run"])
+
+		done, err = runHooks(config, spec, release.HookPreInstall)
+		require.NoError(t, err)
+		require.False(t, done)
+
+		setJobCondition(t, c, batchv1.JobComplete)
+
+		done, err = runHooks(config, spec, release.HookPreInstall)
+		require.NoError(t, err)
+		require.True(t, done)
+
+		err = c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, job)
+		require.True(t, k8serrors.IsNotFound(err))
+	})
+
+	t.Run("run hooks in order of weights", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		config := fixHookConfig(c)
+		spec := ContextManifest{
+			Hooks: []*release.Hook{
+				fixHook("job", testHookJob, 5, []release.HookEvent{release.HookPreUpgrade}),
+				fixHook("cm", testHookConfigMap, -5, []release.HookEvent{release.HookPreUpgrade}),
+			},
+		}
+
+		done, err := runHooks(config, spec, release.HookPreUpgrade)
+		require.NoError(t, err)
+		require.False(t, done)
+
+		// config map is created before the job
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-hook-cm", Namespace: "default"}, &corev1.ConfigMap{}))
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, &batchv1.Job{}))
+	})
+
+	t.Run("skip hooks of other events", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		config := fixHookConfig(c)
+		spec := ContextManifest{
+			Hooks: []*release.Hook{
+				fixHook("job", testHookJob, 0, []release.HookEvent{release.HookPostInstall}),
+			},
+		}
+
+		done, err := runHooks(config, spec, release.HookPreInstall)
+		require.NoError(t, err)
+		require.True(t, done)
+
+		err = c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, &batchv1.Job{})
+		require.True(t, k8serrors.IsNotFound(err))
+	})
+
+	t.Run("delete job left by the previous run before creation", func(t *testing.T) {
+		oldJob := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-hook-job",
+				Namespace:   "default",
+				Annotations: map[string]string{"test-manager.kyma-project.io/hook-run": "previous"},
+			},
+		}
+		c := fake.NewClientBuilder().WithObjects(oldJob).Build()
+		config := fixHookConfig(c)
+		spec := ContextManifest{
+			Hooks: []*release.Hook{
+				fixHook("job", testHookJob, 0, []release.HookEvent{release.HookPreUpgrade}),
+			},
+		}
+
+		done, err := runHooks(config, spec, release.HookPreUpgrade)
+		require.NoError(t, err)
+		require.False(t, done)
+
+		err = c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, &batchv1.Job{})
+		require.True(t, k8serrors.IsNotFound(err))
+
+		done, err = runHooks(config, spec, release.HookPreUpgrade)
+		require.NoError(t, err)
+		require.False(t, done)
+
+		job := &batchv1.Job{}
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, job))
+		require.Equal(t, hookRunID(spec, release.HookPreUpgrade), job.GetAnnotations()["test-manager.kyma-project.io/hook-run"])
+	})
+
+	t.Run("return error and delete failed job", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		config := fixHookConfig(c)
+		spec := ContextManifest{
+			Hooks: []*release.Hook{
+				fixHook("job", testHookJob, 0, []release.HookEvent{release.HookPreInstall}, release.HookFailed),
+			},
+		}
+
+		_, err := runHooks(config, spec, release.HookPreInstall)
+		require.NoError(t, err)
+
+		setJobCondition(t, c, batchv1.JobFailed)
+
+		done, err := runHooks(config, spec, release.HookPreInstall)
+		require.ErrorContains(t, err, "Job default/test-hook-job failed")
+		require.False(t, done)
+
+		err = c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, &batchv1.Job{})
+		require.True(t, k8serrors.IsNotFound(err))
+	})
+}
+
+func Test_install_hooks(t *testing.T) {
+	t.Run("run pre and post install hooks", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		config := fixHookConfig(c)
+		renderFunc := func(_ *Config, _ map[string]interface{}) (*release.Release, error) {
+			return &release.Release{
+				Manifest: testDeploy,
+				Hooks: []*release.Hook{
+					fixHook("pre", testHookJob, 0, []release.HookEvent{release.HookPreInstall}, release.HookSucceeded),
+					fixHook("post", testHookConfigMap, 0, []release.HookEvent{release.HookPostInstall}),
+				},
+			}, nil
+		}
+
+		_, err := install(config, &InstallOpts{}, renderFunc)
+		require.ErrorIs(t, err, ErrHooksInProgress)
+
+		// manifest is not applied until pre-install hooks are completed
+		err = c.Get(context.Background(), types.NamespacedName{Name: "test-deploy", Namespace: "default"}, testDeployCR.DeepCopy())
+		require.True(t, k8serrors.IsNotFound(err))
+
+		setJobCondition(t, c, batchv1.JobComplete)
+
+		_, err = install(config, &InstallOpts{}, renderFunc)
+		require.NoError(t, err)
+
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-deploy", Namespace: "default"}, testDeployCR.DeepCopy()))
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-hook-cm", Namespace: "default"}, &corev1.ConfigMap{}))
+
+		spec, err := config.Cache.Get(context.Background(), testHookKey)
+		require.NoError(t, err)
+		require.Len(t, spec.Hooks, 2)
+		require.Empty(t, spec.PendingHooks)
+	})
+
+	t.Run("run post upgrade hooks in the next reconciliation", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		config := fixHookConfig(c)
+		_ = config.Cache.Set(context.Background(), testHookKey, ContextManifest{Manifest: testServiceAccount, Revision: 1})
+		renderFunc := func(_ *Config, _ map[string]interface{}) (*release.Release, error) {
+			return &release.Release{
+				Manifest: testDeploy,
+				Hooks: []*release.Hook{
+					fixHook("post", testHookJob, 0, []release.HookEvent{release.HookPostUpgrade}),
+				},
+			}, nil
+		}
+
+		_, err := install(config, &InstallOpts{}, renderFunc)
+		require.ErrorIs(t, err, ErrHooksInProgress)
+
+		spec, err := config.Cache.Get(context.Background(), testHookKey)
+		require.NoError(t, err)
+		require.Equal(t, 2, spec.Revision)
+		require.Equal(t, release.HookPostUpgrade, spec.PendingHooks)
+
+		setJobCondition(t, c, batchv1.JobComplete)
+
+		_, err = install(config, &InstallOpts{}, renderFunc)
+		require.NoError(t, err)
+
+		spec, err = config.Cache.Get(context.Background(), testHookKey)
+		require.NoError(t, err)
+		require.Equal(t, 2, spec.Revision)
+		require.Empty(t, spec.PendingHooks)
+	})
+
+	t.Run("don't run hooks when only the manager has changed", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		config := fixHookConfig(c)
+		hooks := []*release.Hook{
+			fixHook("pre", testHookJob, 0, []release.HookEvent{release.HookPreUpgrade}),
+		}
+		_ = config.Cache.Set(context.Background(), testHookKey, ContextManifest{
+			ManagerUID: "old-uid",
+			Manifest:   testDeploy,
+			Hooks:      hooks,
+			Revision:   1,
+		})
+		renderFunc := func(_ *Config, _ map[string]interface{}) (*release.Release, error) {
+			return &release.Release{Manifest: testDeploy, Hooks: hooks}, nil
+		}
+
+		_, err := install(config, &InstallOpts{}, renderFunc)
+		require.NoError(t, err)
+
+		err = c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, &batchv1.Job{})
+		require.True(t, k8serrors.IsNotFound(err))
+
+		spec, err := config.Cache.Get(context.Background(), testHookKey)
+		require.NoError(t, err)
+		require.Equal(t, 2, spec.Revision)
+		require.Equal(t, "test-uid", spec.ManagerUID)
+	})
+}
+
+func Test_install_abortedUninstallHooks(t *testing.T) {
+	c := fake.NewClientBuilder().Build()
+	config := fixHookConfig(c)
+	hooks := []*release.Hook{
+		fixHook("post", testHookJob, 0, []release.HookEvent{release.HookPostDelete}),
+	}
+	_ = config.Cache.Set(context.Background(), testHookKey, ContextManifest{
+		ManagerUID:   "test-uid",
+		Manifest:     testDeploy,
+		Hooks:        hooks,
+		PendingHooks: release.HookPostDelete,
+		Revision:     1,
+	})
+
+	_, err := install(config, &InstallOpts{}, func(_ *Config, _ map[string]interface{}) (*release.Release, error) {
+		return &release.Release{Manifest: testDeploy, Hooks: hooks}, nil
+	})
+	require.NoError(t, err)
+
+	err = c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, &batchv1.Job{})
+	require.True(t, k8serrors.IsNotFound(err))
+
+	spec, err := config.Cache.Get(context.Background(), testHookKey)
+	require.NoError(t, err)
+	require.Empty(t, spec.PendingHooks)
+}
+
+func Test_hookRunID(t *testing.T) {
+	spec := ContextManifest{Manifest: testDeploy, Values: map[string]interface{}{"replicas": 1}}
+	changedSpec := ContextManifest{Manifest: testDeploy, Values: map[string]interface{}{"replicas": 2}}
+	cachedSpec := ContextManifest{Manifest: testDeploy, Values: map[string]interface{}{"replicas": int64(1)}}
+
+	require.NotEqual(t, hookRunID(spec, release.HookPostUpgrade), hookRunID(changedSpec, release.HookPostUpgrade))
+	require.Equal(t, hookRunID(spec, release.HookPostUpgrade), hookRunID(cachedSpec, release.HookPostUpgrade))
+}
+
+func Test_hookRunAnnotation(t *testing.T) {
+	key, err := hookRunAnnotation("test-manager")
+	require.NoError(t, err)
+	require.Equal(t, "test-manager.kyma-project.io/hook-run", key)
+
+	_, err = hookRunAnnotation("")
+	require.ErrorContains(t, err, "manager name '' can't be used to annotate hook objects")
+
+	_, err = hookRunAnnotation("Test_Manager")
+	require.Error(t, err)
+}
+
+func Test_uninstall_hooks(t *testing.T) {
+	t.Run("run pre and post delete hooks", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		config := fixHookConfig(c)
+		_ = config.Cache.Set(context.Background(), testHookKey, ContextManifest{
+			Hooks: []*release.Hook{
+				fixHook("pre", testHookJob, 0, []release.HookEvent{release.HookPreDelete}, release.HookSucceeded),
+				fixHook("post", testHookConfigMap, 0, []release.HookEvent{release.HookPostDelete}),
+			},
+		})
+
+		done, err := Uninstall(config, &UninstallOpts{})
+		require.NoError(t, err)
+		require.False(t, done)
+
+		setJobCondition(t, c, batchv1.JobComplete)
+
+		done, err = Uninstall(config, &UninstallOpts{})
+		require.NoError(t, err)
+		require.True(t, done)
+
+		err = c.Get(context.Background(), types.NamespacedName{Name: "test-hook-job", Namespace: "default"}, &batchv1.Job{})
+		require.True(t, k8serrors.IsNotFound(err))
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-hook-cm", Namespace: "default"}, &corev1.ConfigMap{}))
+	})
+}
+
+func TestRunTests(t *testing.T) {
+	c := fake.NewClientBuilder().Build()
+	config := fixHookConfig(c)
+	_ = config.Cache.Set(context.Background(), testHookKey, ContextManifest{
+		Manifest: testDeploy,
+		Hooks: []*release.Hook{
+			fixHook("test", testHookJob, 0, []release.HookEvent{release.HookTest}),
+		},
+	})
+
+	done, err := RunTests(config)
+	require.NoError(t, err)
+	require.False(t, done)
+
+	setJobCondition(t, c, batchv1.JobComplete)
+
+	done, err = RunTests(config)
+	require.NoError(t, err)
+	require.True(t, done)
+}
@@ -2,11 +2,16 @@ package chart
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/kyma-project/manager-toolkit/installation/base/annotation"
+	"github.com/kyma-project/manager-toolkit/installation/base/resource"
 	"github.com/kyma-project/manager-toolkit/installation/chart/action"
 
+	"go.opentelemetry.io/otel/attribute"
+	"helm.sh/helm/v3/pkg/postrender"
 	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -16,43 +21,214 @@ type InstallOpts struct {
 	// CustomFlags allows passing custom values to the Helm chart renderer
 	CustomFlags map[string]interface{}
 
+	// Values are sources of values merged in the given order before the CustomFlags
+	// the following source overrides values from the previous ones
+	Values []ValuesSource
+
 	// PreActions are functions executed before applying each resource
 	// can be used to modify resources before installation
 	PreActions []action.PreApply
+
+	// PostActions are functions executed after applying each resource
+	// they receive the live object returned by the server, e.g. to read generated fields
+	PostActions []action.PostApply
+
+	// PostInstallActions are functions executed once after all resources are applied and unused ones are removed
+	PostInstallActions []action.PostInstall
+
+	// ContextPreActions, ContextPostActions and ContextPostInstallActions are context-aware variants of the actions above
+	// they are executed after the corresponding actions without the context
+	ContextPreActions         []action.ContextPreApply
+	ContextPostActions        []action.ContextPostApply
+	ContextPostInstallActions []action.ContextPostInstall
+
+	// ValueResolvers add flags based on the cluster state before the chart is rendered
+	// resolved flags are overridden by the CustomFlags
+	ValueResolvers []ValueResolver
+
+	// PostRenderer modifies the whole rendered manifest before it's applied and stored in the cache
+	// the cached manifest is used only if the post renderer implements the `Digest() string` method
+	// returning the same value as before, otherwise the chart is rendered on every installation
+	PostRenderer postrender.PostRenderer
+
+	// ForceRender renders the chart again even if the cached manifest is up-to-date
+	// hooks are run and a new revision is stored only if the rendered manifest differs from the cached one
+	ForceRender bool
+}
+
+// InstallObject describes the object handled during the installation
+type InstallObject struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// InstallResult describes what the installation has changed in the cluster
+type InstallResult struct {
+	// Rendered indicates whether the chart has been rendered again instead of using the cached manifest
+	Rendered bool
+	// Created objects did not exist before the installation
+	Created []InstallObject
+	// Updated objects have been changed by the server-side apply, changes made by others are not taken into account
+	Updated []InstallObject
+	// Unchanged objects have been applied without any change
+	Unchanged []InstallObject
+	// Pruned objects are not part of the manifest anymore and their deletion has been requested
+	Pruned []InstallObject
+	// Skipped objects have not been applied because the installation stopped on the error
+	Skipped []InstallObject
+	// RenderDuration is the time of getting the cached manifest or rendering the chart
+	RenderDuration time.Duration
+	// ApplyDuration is the time of applying and pruning objects
+	ApplyDuration time.Duration
+
+	// applyTimes are times of the last changes made by the apply to objects
+	applyTimes map[string]string
+	// cacheHit indicates whether the cached manifest has been used, it's false when the installation failed before
+	cacheHit bool
+}
+
+// Changed indicates whether any object has been created, updated or pruned
+func (r *InstallResult) Changed() bool {
+	return len(r.Created) > 0 || len(r.Updated) > 0 || len(r.Pruned) > 0
 }
 
 // Install deploys the chart resources to the cluster based on the provided configuration and installation options
 func Install(config *Config, opts *InstallOpts) error {
-	return install(config, opts, renderChart)
+	_, err := InstallWithResult(config, opts)
+	return err
+}
+
+// InstallWithResult works like the Install but returns the result describing applied objects
+// the result is returned also with the error
+func InstallWithResult(config *Config, opts *InstallOpts) (*InstallResult, error) {
+	result, err := install(config, opts, renderChart)
+	observeInstall(config, result)
+	recordInstallEvent(config, result, err)
+	return result, err
+}
+
+func install(config *Config, opts *InstallOpts, renderChartFunc func(config *Config, customFlags map[string]interface{}) (*release.Release, error)) (*InstallResult, error) {
+	config, span := startSpan(config, "chart.install", releaseAttributes(config)...)
+	result, err := installChart(config, opts, renderChartFunc)
+	span.SetAttributes(attribute.Bool("chart.rendered", result.Rendered))
+	endSpan(span, err)
+	return result, err
 }
 
-func install(config *Config, opts *InstallOpts, renderChartFunc func(config *Config, customFlags map[string]interface{}) (*release.Release, error)) error {
-	cachedManifest, currentManifest, err := getCachedAndCurrentManifest(config, opts.CustomFlags, renderChartFunc)
+func installChart(config *Config, opts *InstallOpts, renderChartFunc func(config *Config, customFlags map[string]interface{}) (*release.Release, error)) (*InstallResult, error) {
+	result := &InstallResult{}
+	customFlags, err := resolveFlags(config, opts.ValueResolvers, opts.CustomFlags)
 	if err != nil {
-		return err
+		return result, fmt.Errorf("could not resolve values: %s", err.Error())
 	}
 
-	objs, unusedObjs, err := getObjectsToInstallAndRemove(cachedManifest, currentManifest)
+	renderOpts := *opts
+	renderOpts.CustomFlags = customFlags
+	opts = &renderOpts
+
+	renderStart := time.Now()
+	cachedSpec, currentSpec, rendered, err := getCachedAndCurrentManifest(config, opts, renderChartFunc)
+	result.RenderDuration = time.Since(renderStart)
+	result.Rendered = rendered
 	if err != nil {
-		return err
+		return result, err
+	}
+
+	result.cacheHit = !rendered
+	isNewRevision := isSpecChanged(cachedSpec, currentSpec)
+	currentSpec.PendingHooks = pendingInstallHooks(cachedSpec)
+	if shouldRunInstallHooks(cachedSpec, currentSpec) {
+		preEvent, postEvent := installHookEvents(cachedSpec)
+		done, err := runHooks(config, currentSpec, preEvent)
+		if err != nil {
+			return result, recordFailedRevision(config, cachedSpec, currentSpec, err)
+		}
+		if !done {
+			return result, ErrHooksInProgress
+		}
+
+		currentSpec.PendingHooks = pendingHookEvent(currentSpec.Hooks, postEvent)
 	}
 
-	err = updateObjects(config, objs, opts.PreActions)
+	objs, unusedObjs, err := getObjectsToInstallAndRemove(cachedSpec.Manifest, currentSpec.Manifest)
 	if err != nil {
-		return err
+		return result, err
+	}
+
+	applyStart := time.Now()
+	appliedObjs, err := updateObjects(config, objs,
+		action.ContextPreApplies(opts.PreActions, opts.ContextPreActions),
+		action.ContextPostApplies(opts.PostActions, opts.ContextPostActions),
+		cachedSpec.ApplyTimes, result)
+	if err != nil {
+		result.ApplyDuration = time.Since(applyStart)
+		return result, recordFailedRevision(config, cachedSpec, currentSpec, err)
 	}
 
 	// TODO: check if objects are deleted successfully
-	_, err = deleteObjects(config, unusedObjs)
+	err = pruneObjects(config, unusedObjs, result)
+	result.ApplyDuration = time.Since(applyStart)
+	if err != nil {
+		return result, err
+	}
+
+	postInstallActions := action.ContextPostInstalls(opts.PostInstallActions, opts.ContextPostInstallActions)
+	err = action.FireAllContextPostInstall(newCallbackContext(config, nil), postInstallActions, appliedObjs)
+	if err != nil {
+		return result, err
+	}
+
+	hooksErr := runPendingHooks(config, &currentSpec)
+
+	cacheConfig, span := startSpan(config, "chart.cache.set")
+	currentSpec.ApplyTimes = result.applyTimes
+	if !isNewRevision {
+		// nothing has changed since the last installation
+		cachedSpec.PendingHooks = currentSpec.PendingHooks
+		cachedSpec.ApplyTimes = currentSpec.ApplyTimes
+		err = config.Cache.Set(cacheConfig.Ctx, config.CacheKey, cachedSpec)
+	} else {
+		err = config.Cache.Set(cacheConfig.Ctx, config.CacheKey, withDeployedRevision(config, cachedSpec, currentSpec))
+	}
+	endSpan(span, err)
+	if err != nil {
+		return result, err
+	}
+
+	return result, hooksErr
+}
+
+// runPendingHooks runs post hooks of the applied manifest and clears them when completed
+func runPendingHooks(config *Config, spec *ContextManifest) error {
+	if spec.PendingHooks == "" {
+		return nil
+	}
+
+	done, err := runHooks(config, *spec, spec.PendingHooks)
 	if err != nil {
 		return err
 	}
+	if !done {
+		return ErrHooksInProgress
+	}
 
-	return config.Cache.Set(config.Ctx, config.CacheKey, ContextManifest{
-		ManagerUID:  config.ManagerUID,
-		CustomFlags: opts.CustomFlags,
-		Manifest:    currentManifest,
-	})
+	spec.PendingHooks = ""
+	return nil
+}
+
+// recordFailedRevision stores the failed revision in the history (if enabled) and returns the installation error
+func recordFailedRevision(config *Config, cachedSpec ContextManifest, failedSpec ContextManifest, installErr error) error {
+	if config.MaxHistory <= 0 {
+		return installErr
+	}
+
+	err := config.Cache.Set(config.Ctx, config.CacheKey, withFailedRevision(config, cachedSpec, failedSpec))
+	if err != nil {
+		config.Log.Warnf("could not store failed revision in cache: %s", err.Error())
+	}
+
+	return installErr
 }
 
 func getObjectsToInstallAndRemove(cachedManifest string, currentManifest string) ([]unstructured.Unstructured, []unstructured.Unstructured, error) {
@@ -70,32 +246,141 @@ func getObjectsToInstallAndRemove(cachedManifest string, currentManifest string)
 	return objs, unusedObjs, nil
 }
 
-func updateObjects(config *Config, objs []unstructured.Unstructured, preApplyFuncs []action.PreApply) error {
+// updateObjects applies objects and returns their live state returned by the server
+// the result is updated with objects created, updated or left unchanged by the apply
+// comparing managed fields of the manager returned by the server with the ones returned by the previous apply
+func updateObjects(config *Config, objs []unstructured.Unstructured, preApplyFuncs []action.ContextPreApply, postApplyFuncs []action.ContextPostApply, previousTimes map[string]string, result *InstallResult) ([]unstructured.Unstructured, error) {
+	result.applyTimes = make(map[string]string, len(objs))
+	config, span := startSpan(config, "chart.apply", attribute.Int("chart.objects", len(objs)))
+	appliedObjs := make([]unstructured.Unstructured, 0, len(objs))
 	for i := range objs {
 		u := objs[i]
 		config.Log.Debugf("creating %s %s/%s", u.GetKind(), u.GetNamespace(), u.GetName())
 
-		u = annotation.AddDoNotEditDisclaimer(config.ManagerName, u)
+		objConfig, objSpan := startSpan(config, "chart.apply.object", objectAttributes(&u)...)
+		err := updateObject(objConfig, &u, preApplyFuncs, postApplyFuncs, previousTimes, result)
+		endSpan(objSpan, err)
+		if err != nil {
+			for j := i; j < len(objs); j++ {
+				result.Skipped = append(result.Skipped, newInstallObject(&objs[j]))
+			}
+			endSpan(span, err)
+			return nil, err
+		}
+
+		appliedObjs = append(appliedObjs, u)
+	}
+
+	endSpan(span, nil)
+	return appliedObjs, nil
+}
+
+func updateObject(config *Config, u *unstructured.Unstructured, preApplyFuncs []action.ContextPreApply, postApplyFuncs []action.ContextPostApply, previousTimes map[string]string, result *InstallResult) error {
+	*u = annotation.AddDoNotEditDisclaimer(config.ManagerName, *u)
+
+	err := action.FireAllContextPreApply(newCallbackContext(config, u), preApplyFuncs, u)
+	if err != nil {
+		return err
+	}
 
-		err := action.FireAllPreApply(preApplyFuncs, &u)
+	key := applyTimeKey(u)
+	previousTime, applied := previousTimes[key]
+	var live *unstructured.Unstructured
+	if !applied {
+		// objects not applied by the previous installation (e.g. stored in the cache by an older version) are read
+		// to find out whether they exist
+		live, err = getLiveObject(config, u)
 		if err != nil {
 			return err
 		}
+	}
+
+	// TODO: what if Apply returns error in the middle of manifest?
+	// maybe we should in this case translate applied objs into manifest and set it into cache?
+	// TODO2: is this still valid?
+	err = config.Cluster.Client.Apply(config.Ctx, client.ApplyConfigurationFromUnstructured(u), &client.ApplyOptions{
+		Force:        ptr.To(true),
+		FieldManager: config.ManagerName,
+	})
+	if err != nil {
+		return fmt.Errorf("could not install object %s/%s: %s", u.GetNamespace(), u.GetName(), err.Error())
+	}
 
-		// TODO: what if Apply returns error in the middle of manifest?
-		// maybe we should in this case translate applied objs into manifest and set it into cache?
-		// TODO2: is this still valid?
-		err = config.Cluster.Client.Apply(config.Ctx, client.ApplyConfigurationFromUnstructured(&u), &client.ApplyOptions{
-			Force:        ptr.To(true),
-			FieldManager: config.ManagerName,
-		})
+	// u contains the object returned by the server
+	// the apply time of the manager changes only when the apply has changed the object, writes of other actors are ignored
+	currentTime := managerApplyTime(u, config.ManagerName)
+	result.applyTimes[key] = currentTime
+	switch {
+	case !applied && live == nil:
+		result.Created = append(result.Created, newInstallObject(u))
+	case !applied && live.GetResourceVersion() == u.GetResourceVersion():
+		result.Unchanged = append(result.Unchanged, newInstallObject(u))
+	case applied && previousTime == currentTime:
+		result.Unchanged = append(result.Unchanged, newInstallObject(u))
+	default:
+		result.Updated = append(result.Updated, newInstallObject(u))
+	}
+
+	return action.FireAllContextPostApply(newCallbackContext(config, u), postApplyFuncs, u)
+}
+
+// pruneObjects removes objects which are not part of the manifest anymore
+func pruneObjects(config *Config, objs []unstructured.Unstructured, result *InstallResult) error {
+	config, span := startSpan(config, "chart.prune", attribute.Int("chart.objects", len(objs)))
+	for i := range objs {
+		notFound, err := resource.Delete(config.Ctx, config.Cluster.Client, config.Log, objs[i])
 		if err != nil {
-			return fmt.Errorf("could not install object %s/%s: %s", u.GetNamespace(), u.GetName(), err.Error())
+			endSpan(span, err)
+			return err
+		}
+
+		if !notFound {
+			result.Pruned = append(result.Pruned, newInstallObject(&objs[i]))
 		}
 	}
+
+	endSpan(span, nil)
 	return nil
 }
 
+// managerApplyTime returns the time of the last change made by the manager's apply from the object managed fields
+func managerApplyTime(u *unstructured.Unstructured, manager string) string {
+	for _, entry := range u.GetManagedFields() {
+		if entry.Manager == manager && entry.Operation == metav1.ManagedFieldsOperationApply && entry.Time != nil {
+			return entry.Time.UTC().Format(time.RFC3339)
+		}
+	}
+
+	return ""
+}
+
+// applyTimeKey identifies the object in apply times stored in the cache
+func applyTimeKey(u *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s", u.GroupVersionKind().GroupKind().String(), u.GetNamespace(), u.GetName())
+}
+
+func newInstallObject(u *unstructured.Unstructured) InstallObject {
+	return InstallObject{Kind: u.GetKind(), Namespace: u.GetNamespace(), Name: u.GetName()}
+}
+
+// newCallbackContext returns the context passed to callbacks with the logger scoped to the given object
+func newCallbackContext(config *Config, u *unstructured.Unstructured) action.CallbackContext {
+	log := config.Log
+	if u != nil {
+		log = log.With("kind", u.GetKind(), "namespace", u.GetNamespace(), "name", u.GetName())
+	}
+
+	return action.CallbackContext{
+		Ctx:              config.Ctx,
+		Log:              log,
+		Client:           config.Cluster.Client,
+		RestConfig:       config.Cluster.Config,
+		ManagerName:      config.ManagerName,
+		ReleaseName:      config.Release.Name,
+		ReleaseNamespace: config.Release.Namespace,
+	}
+}
+
 func unusedOldObjects(previousObjs []unstructured.Unstructured, currentObjs []unstructured.Unstructured) []unstructured.Unstructured {
 	currentNames := make(map[string]struct{}, len(currentObjs))
 	for _, obj := range currentObjs {
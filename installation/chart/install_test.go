@@ -2,12 +2,19 @@ package chart
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/kyma-project/manager-toolkit/installation/base/resource"
+	"github.com/kyma-project/manager-toolkit/installation/chart/action"
 
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -15,8 +22,12 @@ import (
 	apiextensionsscheme "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/scheme"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 )
 
 const (
@@ -82,6 +93,64 @@ var (
 	}
 )
 
+type fixConfigOpt func(t *testing.T, config *Config)
+
+// fixConfig returns config with the in-memory cache and the fake client modified by options
+func fixConfig(t *testing.T, opts ...fixConfigOpt) *Config {
+	config := &Config{
+		Ctx:         context.Background(),
+		Log:         zap.NewNop().Sugar(),
+		Cache:       NewInMemoryManifestCache(),
+		CacheKey:    types.NamespacedName{Name: "test", Namespace: "testnamespace"},
+		ManagerUID:  "uid",
+		ManagerName: "test-manager",
+		Release:     Release{Name: "test-release", Namespace: "test-namespace"},
+		Cluster: Cluster{
+			Client: fake.NewClientBuilder().Build(),
+		},
+	}
+
+	for _, opt := range opts {
+		opt(t, config)
+	}
+
+	return config
+}
+
+// withCachedManifest stores the manifest in the cache as the installed one
+func withCachedManifest(manifest string) fixConfigOpt {
+	return func(t *testing.T, config *Config) {
+		require.NoError(t, config.Cache.Set(context.Background(), config.CacheKey, ContextManifest{Manifest: manifest}))
+	}
+}
+
+// withObjects replaces the cluster client with the fake one containing objects
+func withObjects(objs ...client.Object) fixConfigOpt {
+	return func(_ *testing.T, config *Config) {
+		config.Cluster.Client = fake.NewClientBuilder().WithObjects(objs...).Build()
+	}
+}
+
+// withManagerName is used to not share metrics between tests
+func withManagerName(name string) fixConfigOpt {
+	return func(_ *testing.T, config *Config) {
+		config.ManagerName = name
+	}
+}
+
+func withEventRecorder(recorder record.EventRecorder) fixConfigOpt {
+	return func(_ *testing.T, config *Config) {
+		config.EventRecorder = recorder
+		config.EventObject = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-module"}}
+	}
+}
+
+func withSpanRecorder(recorder *tracetest.SpanRecorder) fixConfigOpt {
+	return func(_ *testing.T, config *Config) {
+		config.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	}
+}
+
 func Test_install_delete(t *testing.T) {
 	t.Run("should delete all unused resources", func(t *testing.T) {
 		testManifestKey := types.NamespacedName{
@@ -104,7 +173,7 @@ func Test_install_delete(t *testing.T) {
 			},
 			Log: zap.NewNop().Sugar(),
 		}
-		err := install(config, opts, fixManifestRenderFunc(""))
+		_, err := install(config, opts, fixManifestRenderFunc(""))
 		require.NoError(t, err)
 
 		deploymentList := appsv1.DeploymentList{}
@@ -119,6 +188,123 @@ func Test_install_delete(t *testing.T) {
 	})
 }
 
+func Test_install_history(t *testing.T) {
+	testManifestKey := types.NamespacedName{
+		Name: "test", Namespace: "testnamespace",
+	}
+
+	t.Run("should store new revision", func(t *testing.T) {
+		cache := NewInMemoryManifestCache()
+		_ = cache.Set(context.Background(), testManifestKey,
+			ContextManifest{Manifest: testCRD, Revision: 1})
+		config := &Config{
+			Ctx:        context.Background(),
+			Cache:      cache,
+			CacheKey:   testManifestKey,
+			ManagerUID: "new-uid",
+			MaxHistory: 2,
+			Cluster: Cluster{
+				Client: fake.NewClientBuilder().WithObjects(testCRDObj.DeepCopy()).Build(),
+			},
+			Log: zap.NewNop().Sugar(),
+		}
+
+		_, err := install(config, &InstallOpts{}, fixManifestRenderFunc(""))
+		require.NoError(t, err)
+
+		spec, err := cache.Get(context.Background(), testManifestKey)
+		require.NoError(t, err)
+		require.Equal(t, 2, spec.Revision)
+		require.Equal(t, "new-uid", spec.ManagerUID)
+		require.Len(t, spec.History, 1)
+		require.Equal(t, RevisionSuperseded, spec.History[0].Outcome)
+	})
+
+	t.Run("should not store new revision when forced render produces the same spec", func(t *testing.T) {
+		cache := NewInMemoryManifestCache()
+		_ = cache.Set(context.Background(), testManifestKey,
+			ContextManifest{ManagerUID: "uid", Manifest: testCRD, Revision: 1})
+		config := &Config{
+			Ctx:         context.Background(),
+			Cache:       cache,
+			CacheKey:    testManifestKey,
+			ManagerUID:  "uid",
+			ManagerName: "test-manager",
+			MaxHistory:  2,
+			Cluster: Cluster{
+				Client: fake.NewClientBuilder().WithObjects(testCRDObj.DeepCopy()).Build(),
+			},
+			Log: zap.NewNop().Sugar(),
+		}
+
+		result, err := install(config, &InstallOpts{ForceRender: true}, fixManifestRenderFunc(testCRD))
+		require.NoError(t, err)
+		require.True(t, result.Rendered)
+
+		spec, err := cache.Get(context.Background(), testManifestKey)
+		require.NoError(t, err)
+		require.Equal(t, 1, spec.Revision)
+		require.Empty(t, spec.History)
+	})
+
+	t.Run("should store resolved flags", func(t *testing.T) {
+		cache := NewInMemoryManifestCache()
+		config := &Config{
+			Ctx:      context.Background(),
+			Cache:    cache,
+			CacheKey: testManifestKey,
+			Cluster: Cluster{
+				Client: fake.NewClientBuilder().Build(),
+			},
+			Log: zap.NewNop().Sugar(),
+		}
+		opts := &InstallOpts{
+			CustomFlags: map[string]interface{}{"flag1": "val1"},
+			ValueResolvers: []ValueResolver{
+				func(_ context.Context, _ Cluster, flags FlagsBuilder) error {
+					flags.With("flag2", "val2")
+					return nil
+				},
+			},
+		}
+
+		_, err := install(config, opts, fixManifestRenderFunc(""))
+		require.NoError(t, err)
+
+		spec, err := cache.Get(context.Background(), testManifestKey)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"flag1": "val1", "flag2": "val2"}, spec.CustomFlags)
+		require.Equal(t, map[string]interface{}{"flag1": "val1"}, opts.CustomFlags)
+	})
+
+	t.Run("should record failed revision", func(t *testing.T) {
+		cache := NewInMemoryManifestCache()
+		_ = cache.Set(context.Background(), testManifestKey,
+			ContextManifest{Manifest: "", Revision: 1})
+		config := &Config{
+			Ctx:        context.Background(),
+			Cache:      cache,
+			CacheKey:   testManifestKey,
+			ManagerUID: "new-uid",
+			MaxHistory: 2,
+			Cluster: Cluster{
+				Client: fake.NewClientBuilder().WithScheme(apiextensionsscheme.Scheme).Build(),
+			},
+			Log: zap.NewNop().Sugar(),
+		}
+
+		_, err := install(config, &InstallOpts{}, fixManifestRenderFunc(testDeploy))
+		require.Error(t, err)
+
+		spec, err := cache.Get(context.Background(), testManifestKey)
+		require.NoError(t, err)
+		require.Equal(t, 1, spec.Revision)
+		require.Len(t, spec.History, 1)
+		require.Equal(t, RevisionFailed, spec.History[0].Outcome)
+		require.Equal(t, testDeploy, spec.History[0].Manifest)
+	})
+}
+
 func Test_install(t *testing.T) {
 	log := zap.NewNop().Sugar()
 
@@ -256,3 +442,250 @@ func Test_unusedOldObjects(t *testing.T) {
 		})
 	}
 }
+
+func Test_install_callbacks(t *testing.T) {
+	t.Run("should fire post apply and post install actions with live objects", func(t *testing.T) {
+		config := &Config{
+			Ctx:         context.Background(),
+			Cache:       NewInMemoryManifestCache(),
+			CacheKey:    types.NamespacedName{Name: "test", Namespace: "testnamespace"},
+			ManagerUID:  "uid",
+			ManagerName: "test-manager",
+			Cluster: Cluster{
+				Client: fake.NewClientBuilder().Build(),
+			},
+			Log: zap.NewNop().Sugar(),
+		}
+
+		appliedNames := []string{}
+		var postInstallObjs []unstructured.Unstructured
+		opts := &InstallOpts{
+			PostActions: []action.PostApply{
+				func(u *unstructured.Unstructured) error {
+					appliedNames = append(appliedNames, u.GetName())
+					return nil
+				},
+			},
+			PostInstallActions: []action.PostInstall{
+				action.PostInstallWithPredicate(func(objs []unstructured.Unstructured) error {
+					postInstallObjs = objs
+					return nil
+				}, resource.IsDeployment),
+			},
+		}
+
+		_, err := install(config, opts, fixManifestRenderFunc(fmt.Sprint(testServiceAccount, separator, testDeploy)))
+		require.NoError(t, err)
+		require.Equal(t, []string{"test-service-account", "test-deploy"}, appliedNames)
+		require.Len(t, postInstallObjs, 1)
+		require.Equal(t, "test-deploy", postInstallObjs[0].GetName())
+	})
+
+	t.Run("should pass callback context to context-aware actions", func(t *testing.T) {
+		c := fake.NewClientBuilder().Build()
+		config := &Config{
+			Ctx:         context.Background(),
+			Cache:       NewInMemoryManifestCache(),
+			CacheKey:    types.NamespacedName{Name: "test", Namespace: "testnamespace"},
+			ManagerUID:  "uid",
+			ManagerName: "test-manager",
+			Release:     Release{Name: "test-release", Namespace: "test-namespace"},
+			Cluster: Cluster{
+				Client: c,
+			},
+			Log: zap.NewNop().Sugar(),
+		}
+
+		phases := []action.Phase{}
+		opts := &InstallOpts{
+			ContextPreActions: []action.ContextPreApply{
+				func(cc action.CallbackContext, u *unstructured.Unstructured) error {
+					require.Equal(t, c, cc.Client)
+					require.Equal(t, "test-release", cc.ReleaseName)
+					require.Equal(t, "test-namespace", cc.ReleaseNamespace)
+					require.Equal(t, "test-manager", cc.ManagerName)
+					require.NotNil(t, cc.Log)
+					phases = append(phases, cc.Phase)
+					return nil
+				},
+			},
+			ContextPostActions: []action.ContextPostApply{
+				func(cc action.CallbackContext, u *unstructured.Unstructured) error {
+					phases = append(phases, cc.Phase)
+					return nil
+				},
+			},
+			ContextPostInstallActions: []action.ContextPostInstall{
+				func(cc action.CallbackContext, objs []unstructured.Unstructured) error {
+					phases = append(phases, cc.Phase)
+					return nil
+				},
+			},
+		}
+
+		_, err := install(config, opts, fixManifestRenderFunc(testDeploy))
+		require.NoError(t, err)
+		require.Equal(t, []action.Phase{action.PhasePreApply, action.PhasePostApply, action.PhasePostInstall}, phases)
+	})
+
+	t.Run("should return post apply error", func(t *testing.T) {
+		config := &Config{
+			Ctx:         context.Background(),
+			Cache:       NewInMemoryManifestCache(),
+			CacheKey:    types.NamespacedName{Name: "test", Namespace: "testnamespace"},
+			ManagerUID:  "uid",
+			ManagerName: "test-manager",
+			Cluster: Cluster{
+				Client: fake.NewClientBuilder().Build(),
+			},
+			Log: zap.NewNop().Sugar(),
+		}
+		opts := &InstallOpts{
+			PostActions: []action.PostApply{
+				func(u *unstructured.Unstructured) error {
+					return errors.New("test error")
+				},
+			},
+		}
+
+		_, err := install(config, opts, fixManifestRenderFunc(testDeploy))
+		require.ErrorContains(t, err, "test error")
+	})
+}
+
+func Test_install_result(t *testing.T) {
+	changedKind := ""
+	writes := map[string]int{}
+	applies := map[string]int{}
+	applyTime := metav1.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := fake.NewClientBuilder().
+		WithObjects(testDeployCR.DeepCopy(), testCRDObj.DeepCopy()).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Apply: func(ctx context.Context, c client.WithWatch, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+				err := c.Apply(ctx, obj, opts...)
+				if err != nil {
+					return err
+				}
+
+				// the fake client doesn't return the live object so the server response is simulated
+				u := &unstructured.Unstructured{}
+				data, err := json.Marshal(obj)
+				require.NoError(t, err)
+				require.NoError(t, u.UnmarshalJSON(data))
+				if u.GetKind() == changedKind {
+					writes[u.GetKind()]++
+					applies[u.GetKind()]++
+				}
+
+				u.SetResourceVersion(fmt.Sprint(999 + writes[u.GetKind()]))
+				u.SetManagedFields([]metav1.ManagedFieldsEntry{{
+					Manager:   "test-manager",
+					Operation: metav1.ManagedFieldsOperationApply,
+					Time:      &metav1.Time{Time: applyTime.Add(time.Duration(applies[u.GetKind()]) * time.Second)},
+				}})
+				data, err = u.MarshalJSON()
+				require.NoError(t, err)
+				return json.Unmarshal(data, obj)
+			},
+		}).
+		Build()
+
+	testManifestKey := types.NamespacedName{Name: "test", Namespace: "testnamespace"}
+	cache := NewInMemoryManifestCache()
+	require.NoError(t, cache.Set(context.Background(), testManifestKey,
+		ContextManifest{
+			Manifest:   fmt.Sprint(testCRD, separator, testDeploy),
+			ApplyTimes: map[string]string{"Deployment.apps/default/test-deploy": applyTime.Format(time.RFC3339)},
+		}))
+
+	config := &Config{
+		Ctx:         context.Background(),
+		Cache:       cache,
+		CacheKey:    testManifestKey,
+		ManagerUID:  "uid",
+		ManagerName: "test-manager",
+		Cluster: Cluster{
+			Client: c,
+		},
+		Log: zap.NewNop().Sugar(),
+	}
+	renderFunc := fixManifestRenderFunc(fmt.Sprint(testServiceAccount, separator, testDeploy))
+
+	t.Run("list created, updated and pruned objects", func(t *testing.T) {
+		changedKind = "Deployment"
+
+		result, err := install(config, &InstallOpts{}, renderFunc)
+		require.NoError(t, err)
+		require.True(t, result.Rendered)
+		require.True(t, result.Changed())
+		require.Equal(t, []InstallObject{{Kind: "ServiceAccount", Namespace: "test-namespace", Name: "test-service-account"}}, result.Created)
+		require.Equal(t, []InstallObject{{Kind: "Deployment", Namespace: "default", Name: "test-deploy"}}, result.Updated)
+		require.Equal(t, []InstallObject{{Kind: "CustomResourceDefinition", Name: "test-crd"}}, result.Pruned)
+		require.Empty(t, result.Unchanged)
+		require.Empty(t, result.Skipped)
+		require.NotZero(t, result.RenderDuration)
+		require.NotZero(t, result.ApplyDuration)
+	})
+
+	t.Run("list unchanged objects", func(t *testing.T) {
+		changedKind = ""
+
+		result, err := install(config, &InstallOpts{}, renderFunc)
+		require.NoError(t, err)
+		require.False(t, result.Rendered)
+		require.False(t, result.Changed())
+		require.Equal(t, []string{"ServiceAccount", "Deployment"}, installKinds(result.Unchanged))
+	})
+
+	t.Run("ignore changes made by others", func(t *testing.T) {
+		// e.g. the status has been updated by the controller
+		writes["Deployment"]++
+
+		result, err := install(config, &InstallOpts{}, renderFunc)
+		require.NoError(t, err)
+		require.False(t, result.Changed())
+		require.Equal(t, []string{"ServiceAccount", "Deployment"}, installKinds(result.Unchanged))
+	})
+
+	t.Run("don't list existing objects missing in the cache as created", func(t *testing.T) {
+		require.NoError(t, cache.Set(context.Background(), testManifestKey,
+			ContextManifest{Manifest: fmt.Sprint(testServiceAccount, separator, testDeploy)}))
+		// the simulated response of the Deployment apply keeps the live resource version
+		live := testDeployCR.DeepCopy()
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-deploy", Namespace: "default"}, live))
+		liveVersion, err := strconv.Atoi(live.ResourceVersion)
+		require.NoError(t, err)
+		writes["Deployment"] = liveVersion - 999
+		changedKind = "ServiceAccount"
+
+		result, err := install(config, &InstallOpts{}, renderFunc)
+		require.NoError(t, err)
+		require.Empty(t, result.Created)
+		require.Equal(t, []string{"ServiceAccount"}, installKinds(result.Updated))
+		require.Equal(t, []string{"Deployment"}, installKinds(result.Unchanged))
+	})
+
+	t.Run("list skipped objects", func(t *testing.T) {
+		changedKind = ""
+		opts := &InstallOpts{
+			PreActions: []action.PreApply{
+				action.PreApplyWithPredicate(func(u *unstructured.Unstructured) error {
+					return errors.New("test error")
+				}, resource.IsDeployment),
+			},
+		}
+
+		result, err := install(config, opts, renderFunc)
+		require.ErrorContains(t, err, "test error")
+		require.Equal(t, []string{"ServiceAccount"}, installKinds(result.Unchanged))
+		require.Equal(t, []string{"Deployment"}, installKinds(result.Skipped))
+	})
+}
+
+func installKinds(objs []InstallObject) []string {
+	kinds := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		kinds = append(kinds, obj.Kind)
+	}
+	return kinds
+}
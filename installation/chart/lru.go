@@ -0,0 +1,102 @@
+package chart
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache is a concurrency-safe cache keeping up to size least recently used entries.
+// Entries expire after ttl, zero ttl means that entries never expire. Size lower than 1 disables the cache.
+type lruCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[K]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+type lruEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+func newLRUCache[K comparable, V any](size int, ttl time.Duration) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		size:    size,
+		ttl:     ttl,
+		entries: map[K]*list.Element{},
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns value for the key and marks it as the most recently used one
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var empty V
+	elem, ok := c.entries[key]
+	if !ok {
+		return empty, false
+	}
+
+	entry := elem.Value.(*lruEntry[K, V])
+	if c.ttl > 0 && c.now().After(entry.expiresAt) {
+		c.removeElement(elem)
+		return empty, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// set stores value for the key and returns true if other entry has been evicted to make space for it
+func (c *lruCache[K, V]) set(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return false
+	}
+
+	expiresAt := c.now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return false
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[K, V]{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+
+	if c.order.Len() <= c.size {
+		return false
+	}
+
+	c.removeElement(c.order.Back())
+	return true
+}
+
+// delete removes the key from the cache
+func (c *lruCache[K, V]) delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+func (c *lruCache[K, V]) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*lruEntry[K, V]).key)
+}
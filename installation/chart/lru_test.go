@@ -0,0 +1,64 @@
+package chart
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_lruCache(t *testing.T) {
+	t.Run("evict least recently used entry", func(t *testing.T) {
+		cache := newLRUCache[string, int](2, 0)
+
+		require.False(t, cache.set("one", 1))
+		require.False(t, cache.set("two", 2))
+
+		// mark "one" as recently used
+		_, ok := cache.get("one")
+		require.True(t, ok)
+
+		require.True(t, cache.set("three", 3))
+
+		_, ok = cache.get("two")
+		require.False(t, ok)
+		value, ok := cache.get("one")
+		require.True(t, ok)
+		require.Equal(t, 1, value)
+	})
+
+	t.Run("update existing entry", func(t *testing.T) {
+		cache := newLRUCache[string, int](1, 0)
+
+		require.False(t, cache.set("one", 1))
+		require.False(t, cache.set("one", 2))
+
+		value, ok := cache.get("one")
+		require.True(t, ok)
+		require.Equal(t, 2, value)
+	})
+
+	t.Run("expire entry", func(t *testing.T) {
+		now := time.Now()
+		cache := newLRUCache[string, int](1, time.Minute)
+		cache.now = func() time.Time { return now }
+
+		cache.set("one", 1)
+		_, ok := cache.get("one")
+		require.True(t, ok)
+
+		now = now.Add(2 * time.Minute)
+		_, ok = cache.get("one")
+		require.False(t, ok)
+	})
+
+	t.Run("delete entry", func(t *testing.T) {
+		cache := newLRUCache[string, int](1, 0)
+
+		cache.set("one", 1)
+		cache.delete("one")
+
+		_, ok := cache.get("one")
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,150 @@
+package chart
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "manager_toolkit"
+	metricsSubsystem = "chart"
+)
+
+var (
+	renderDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "render_duration_seconds",
+		Help:      "Time of rendering the chart manifest.",
+	}, []string{"manager", "release"})
+
+	applyDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "apply_duration_seconds",
+		Help:      "Time of applying and pruning chart objects.",
+	}, []string{"manager", "release"})
+
+	deleteDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "delete_duration_seconds",
+		Help:      "Time of a single uninstallation pass deleting chart objects.",
+	}, []string{"manager", "release"})
+
+	appliedObjectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "applied_objects_total",
+		Help:      "Number of chart objects created or updated by the apply.",
+	}, []string{"manager", "release", "kind"})
+
+	prunedObjectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "pruned_objects_total",
+		Help:      "Number of removed objects which are not part of the chart manifest anymore.",
+	}, []string{"manager", "release", "kind"})
+
+	failedObjectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "failed_objects_total",
+		Help:      "Number of chart objects which could not be applied or deleted.",
+	}, []string{"manager", "release", "kind"})
+
+	manifestCacheTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "manifest_cache_total",
+		Help:      "Number of installations using the cached manifest (hit) or rendering the chart again (miss).",
+	}, []string{"manager", "release", "result"})
+
+	verificationReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "verification_ready",
+		Help:      "Result of the last verification, 1 when all verified objects are ready.",
+	}, []string{"manager", "release"})
+)
+
+// RegisterMetrics registers chart metrics in the registerer
+// e.g. the controller-runtime metrics.Registry to expose them together with the manager metrics
+// metrics are not registered automatically, so importing the package doesn't change the registry of the application
+// and the same metrics can't be registered twice when the toolkit is used by more managers in one binary
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{
+		renderDurationSeconds,
+		applyDurationSeconds,
+		deleteDurationSeconds,
+		appliedObjectsTotal,
+		prunedObjectsTotal,
+		failedObjectsTotal,
+		manifestCacheTotal,
+		verificationReady,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return fmt.Errorf("could not register chart metrics: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+func observeInstall(config *Config, result *InstallResult) {
+	labels := prometheus.Labels{"manager": config.ManagerName, "release": config.Release.Name}
+	// installations failed before using the cache or rendering the chart are neither hits nor misses
+	if result.Rendered {
+		manifestCacheTotal.With(withLabel(labels, "result", "miss")).Inc()
+		renderDurationSeconds.With(labels).Observe(result.RenderDuration.Seconds())
+	} else if result.cacheHit {
+		manifestCacheTotal.With(withLabel(labels, "result", "hit")).Inc()
+	}
+
+	if result.ApplyDuration > 0 {
+		applyDurationSeconds.With(labels).Observe(result.ApplyDuration.Seconds())
+	}
+
+	// unchanged objects are not counted as they're applied during every reconciliation
+	for _, objs := range [][]InstallObject{result.Created, result.Updated} {
+		for _, obj := range objs {
+			appliedObjectsTotal.With(withLabel(labels, "kind", obj.Kind)).Inc()
+		}
+	}
+	for _, obj := range result.Pruned {
+		prunedObjectsTotal.With(withLabel(labels, "kind", obj.Kind)).Inc()
+	}
+	if len(result.Skipped) > 0 {
+		// the first skipped object is the one which has failed
+		failedObjectsTotal.With(withLabel(labels, "kind", result.Skipped[0].Kind)).Inc()
+	}
+}
+
+func observeUninstall(config *Config, result *UninstallResult, duration time.Duration) {
+	labels := prometheus.Labels{"manager": config.ManagerName, "release": config.Release.Name}
+	deleteDurationSeconds.With(labels).Observe(duration.Seconds())
+
+	for _, obj := range result.Failed {
+		failedObjectsTotal.With(withLabel(labels, "kind", obj.Kind)).Inc()
+	}
+}
+
+func observeVerify(config *Config, result *VerificationResult) {
+	ready := 0.0
+	if result != nil && result.Ready {
+		ready = 1
+	}
+
+	verificationReady.With(prometheus.Labels{"manager": config.ManagerName, "release": config.Release.Name}).Set(ready)
+}
+
+// withLabel returns copy of labels with the additional label
+func withLabel(labels prometheus.Labels, name, value string) prometheus.Labels {
+	result := prometheus.Labels{name: value}
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
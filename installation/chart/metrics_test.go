@@ -0,0 +1,101 @@
+package chart
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	t.Run("register metrics", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		require.NoError(t, RegisterMetrics(registry))
+
+		appliedObjectsTotal.With(prometheus.Labels{"manager": t.Name(), "release": "test-release", "kind": "Deployment"}).Inc()
+		count, err := testutil.GatherAndCount(registry, "manager_toolkit_chart_applied_objects_total")
+		require.NoError(t, err)
+		require.NotZero(t, count)
+	})
+
+	t.Run("return error when metrics are already registered", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		require.NoError(t, RegisterMetrics(registry))
+
+		err := RegisterMetrics(registry)
+		require.ErrorContains(t, err, "could not register chart metrics")
+	})
+}
+
+func Test_observeInstall(t *testing.T) {
+	config := fixConfig(t, withManagerName(t.Name()))
+	labels := prometheus.Labels{"manager": config.ManagerName, "release": "test-release"}
+
+	observeInstall(config, &InstallResult{
+		Rendered:       true,
+		Created:        []InstallObject{{Kind: "Deployment"}},
+		Updated:        []InstallObject{{Kind: "Deployment"}},
+		Unchanged:      []InstallObject{{Kind: "ServiceAccount"}},
+		Pruned:         []InstallObject{{Kind: "ConfigMap"}},
+		Skipped:        []InstallObject{{Kind: "Service"}, {Kind: "Deployment"}},
+		RenderDuration: time.Second,
+		ApplyDuration:  time.Second,
+	})
+	observeInstall(config, &InstallResult{cacheHit: true})
+	// failed before using the cache
+	observeInstall(config, &InstallResult{})
+
+	require.Equal(t, 1.0, testutil.ToFloat64(manifestCacheTotal.With(withLabel(labels, "result", "miss"))))
+	require.Equal(t, 1.0, testutil.ToFloat64(manifestCacheTotal.With(withLabel(labels, "result", "hit"))))
+	require.Equal(t, 2.0, testutil.ToFloat64(appliedObjectsTotal.With(withLabel(labels, "kind", "Deployment"))))
+	require.Equal(t, 0.0, testutil.ToFloat64(appliedObjectsTotal.With(withLabel(labels, "kind", "ServiceAccount"))))
+	require.Equal(t, 1.0, testutil.ToFloat64(prunedObjectsTotal.With(withLabel(labels, "kind", "ConfigMap"))))
+	require.Equal(t, 1.0, testutil.ToFloat64(failedObjectsTotal.With(withLabel(labels, "kind", "Service"))))
+	require.Equal(t, 0.0, testutil.ToFloat64(failedObjectsTotal.With(withLabel(labels, "kind", "Deployment"))))
+	require.Equal(t, 1, histogramCount(t, renderDurationSeconds, labels))
+	require.Equal(t, 1, histogramCount(t, applyDurationSeconds, labels))
+}
+
+func Test_Uninstall_metrics(t *testing.T) {
+	config := fixConfig(t, withManagerName(t.Name()), withCachedManifest(testDeploy))
+	labels := prometheus.Labels{"manager": config.ManagerName, "release": "test-release"}
+
+	_, err := Uninstall(config, &UninstallOpts{})
+	require.NoError(t, err)
+
+	observeUninstall(config, &UninstallResult{Failed: []UninstallObject{{Kind: "Deployment"}}}, time.Second)
+
+	require.Equal(t, 2, histogramCount(t, deleteDurationSeconds, labels))
+	require.Equal(t, 1.0, testutil.ToFloat64(failedObjectsTotal.With(withLabel(labels, "kind", "Deployment"))))
+}
+
+func Test_Verify_metrics(t *testing.T) {
+	config := fixConfig(t, withManagerName(t.Name()), withCachedManifest(testDeploy))
+	gauge := verificationReady.With(prometheus.Labels{"manager": config.ManagerName, "release": "test-release"})
+
+	config.Cluster.Client = fake.NewClientBuilder().WithObjects(testDeployCR.DeepCopy()).Build()
+	_, err := Verify(config)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(gauge))
+
+	config.Cluster.Client = fake.NewClientBuilder().WithObjects(testDeployNotReadyCR.DeepCopy()).Build()
+	_, err = Verify(config)
+	require.NoError(t, err)
+	require.Equal(t, 0.0, testutil.ToFloat64(gauge))
+
+	observeVerify(config, nil)
+	require.Equal(t, 0.0, testutil.ToFloat64(gauge))
+}
+
+func histogramCount(t *testing.T, histogram *prometheus.HistogramVec, labels prometheus.Labels) int {
+	observer, err := histogram.GetMetricWith(labels)
+	require.NoError(t, err)
+
+	m := &dto.Metric{}
+	require.NoError(t, observer.(prometheus.Metric).Write(m))
+	return int(m.GetHistogram().GetSampleCount())
+}
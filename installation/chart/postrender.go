@@ -0,0 +1,194 @@
+package chart
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"helm.sh/helm/v3/pkg/postrender"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	_ postrender.PostRenderer = (*patchPostRenderer)(nil)
+	_ digestPostRenderer      = (*patchPostRenderer)(nil)
+)
+
+// digestPostRenderer is a post renderer able to describe its configuration with a digest
+// the manifest is rendered again only when the digest changes, other post renderers force rendering on every installation
+type digestPostRenderer interface {
+	postrender.PostRenderer
+	Digest() string
+}
+
+// PatchTarget selects objects to patch, empty fields match all objects
+type PatchTarget struct {
+	Group     string
+	Version   string
+	Kind      string
+	Name      string
+	Namespace string
+	// LabelSelector is a label selector string, e.g. "app=test,tier!=db"
+	LabelSelector string
+}
+
+// Patch is a strategic merge patch in the YAML or JSON format applied to all objects matching the Target
+// objects of kinds unknown to the client-go scheme (e.g. custom resources) are patched with JSON merge patch
+type Patch struct {
+	Target PatchTarget
+	Patch  string
+}
+
+type patchPostRenderer struct {
+	patches []Patch
+}
+
+// NewPatchPostRenderer returns a kustomize-like post renderer applying patches to the rendered manifest in the given order
+func NewPatchPostRenderer(patches ...Patch) *patchPostRenderer {
+	return &patchPostRenderer{
+		patches: patches,
+	}
+}
+
+// Run applies patches to all matching objects from the rendered manifest
+func (r *patchPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	objs, err := decodeManifest(renderedManifests.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not parse rendered manifest: %s", err.Error())
+	}
+
+	for i := range objs {
+		for _, patch := range r.patches {
+			matches, err := patch.Target.matches(objs[i])
+			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				continue
+			}
+
+			err = applyPatch(&objs[i], patch.Patch)
+			if err != nil {
+				return nil, fmt.Errorf("while patching %s %s/%s: %s", objs[i].GetKind(), objs[i].GetNamespace(), objs[i].GetName(), err.Error())
+			}
+		}
+	}
+
+	return encodeManifest(objs)
+}
+
+// Digest returns a hash of configured patches
+func (r *patchPostRenderer) Digest() string {
+	data, err := json.Marshal(r.patches)
+	if err != nil {
+		// patches contain only strings so it never happens
+		return ""
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+func (t PatchTarget) matches(u unstructured.Unstructured) (bool, error) {
+	gvk := u.GroupVersionKind()
+	if (t.Group != "" && t.Group != gvk.Group) ||
+		(t.Version != "" && t.Version != gvk.Version) ||
+		(t.Kind != "" && t.Kind != gvk.Kind) ||
+		(t.Name != "" && t.Name != u.GetName()) ||
+		(t.Namespace != "" && t.Namespace != u.GetNamespace()) {
+		return false, nil
+	}
+
+	if t.LabelSelector == "" {
+		return true, nil
+	}
+
+	selector, err := labels.Parse(t.LabelSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid label selector '%s': %s", t.LabelSelector, err.Error())
+	}
+
+	return selector.Matches(labels.Set(u.GetLabels())), nil
+}
+
+func applyPatch(u *unstructured.Unstructured, patch string) error {
+	patchJSON, err := yaml.YAMLToJSON([]byte(patch))
+	if err != nil {
+		return fmt.Errorf("invalid patch: %s", err.Error())
+	}
+
+	original, err := json.Marshal(u.Object)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	dataStruct, err := scheme.Scheme.New(u.GroupVersionKind())
+	if err == nil {
+		patched, err = strategicpatch.StrategicMergePatch(original, patchJSON, dataStruct)
+	} else {
+		// strategic merge patch requires the go struct, fall back to the JSON merge patch
+		patched, err = jsonpatch.MergePatch(original, patchJSON)
+	}
+	if err != nil {
+		return err
+	}
+
+	obj := map[string]interface{}{}
+	err = json.Unmarshal(patched, &obj)
+	if err != nil {
+		return err
+	}
+
+	u.Object = obj
+	return nil
+}
+
+func encodeManifest(objs []unstructured.Unstructured) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	for i := range objs {
+		data, err := yaml.Marshal(objs[i].Object)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode %s %s/%s: %s", objs[i].GetKind(), objs[i].GetNamespace(), objs[i].GetName(), err.Error())
+		}
+
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+
+	return buf, nil
+}
+
+// postRendererDigest returns the digest of the post renderer configuration
+// it returns false if the post renderer can't be described by the digest and the manifest has to be rendered again
+func postRendererDigest(postRenderer postrender.PostRenderer) (string, bool) {
+	if postRenderer == nil {
+		return "", true
+	}
+
+	digestRenderer, ok := postRenderer.(digestPostRenderer)
+	if !ok {
+		return "", false
+	}
+
+	return digestRenderer.Digest(), true
+}
+
+// postRenderManifest runs the post renderer on the manifest if configured
+func postRenderManifest(postRenderer postrender.PostRenderer, manifest string) (string, error) {
+	if postRenderer == nil {
+		return manifest, nil
+	}
+
+	result, err := postRenderer.Run(bytes.NewBufferString(manifest))
+	if err != nil {
+		return "", fmt.Errorf("while post rendering manifest: %s", err.Error())
+	}
+
+	return result.String(), nil
+}
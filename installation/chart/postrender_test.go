@@ -0,0 +1,201 @@
+package chart
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	testPatchDeploy = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test-deploy
+  namespace: default
+  labels:
+    app: test
+spec:
+  template:
+    spec:
+      containers:
+      - name: manager
+        image: manager:1.0
+      - name: sidecar
+        image: sidecar:1.0
+`
+	testPatchCR = `
+apiVersion: test.group/v1alpha2
+kind: TestKind
+metadata:
+  name: test-cr
+  namespace: default
+spec:
+  list:
+  - a
+  - b
+  enabled: true
+`
+)
+
+func Test_patchPostRenderer_Run(t *testing.T) {
+	manifest := fmt.Sprint(testPatchDeploy, separator, testPatchCR, separator, testServiceAccount)
+
+	t.Run("apply strategic merge patch to matching objects", func(t *testing.T) {
+		renderer := NewPatchPostRenderer(Patch{
+			Target: PatchTarget{Kind: "Deployment", LabelSelector: "app=test"},
+			Patch: `
+spec:
+  template:
+    spec:
+      containers:
+      - name: manager
+        env:
+        - name: FIPS
+          value: "true"
+`,
+		})
+
+		objs := runPostRenderer(t, renderer, manifest)
+		require.Len(t, objs, 3)
+
+		containers, _, _ := unstructured.NestedSlice(objs[0].Object, "spec", "template", "spec", "containers")
+		require.Len(t, containers, 2)
+		require.Equal(t, "manager:1.0", containers[0].(map[string]interface{})["image"])
+		require.Equal(t, []interface{}{map[string]interface{}{"name": "FIPS", "value": "true"}},
+			containers[0].(map[string]interface{})["env"])
+	})
+
+	t.Run("apply json merge patch to unknown kinds", func(t *testing.T) {
+		renderer := NewPatchPostRenderer(Patch{
+			Target: PatchTarget{Group: "test.group", Kind: "TestKind", Name: "test-cr"},
+			Patch:  `{"spec": {"list": ["c"], "enabled": null}}`,
+		})
+
+		objs := runPostRenderer(t, renderer, manifest)
+		require.Equal(t, map[string]interface{}{"list": []interface{}{"c"}}, objs[1].Object["spec"])
+	})
+
+	t.Run("skip not matching objects", func(t *testing.T) {
+		renderer := NewPatchPostRenderer(
+			Patch{
+				Target: PatchTarget{Kind: "Deployment", Namespace: "other"},
+				Patch:  `{"metadata": {"labels": {"patched": "true"}}}`,
+			},
+			Patch{
+				Target: PatchTarget{Kind: "ServiceAccount", LabelSelector: "label-key!=label-val"},
+				Patch:  `{"metadata": {"labels": {"patched": "true"}}}`,
+			},
+		)
+
+		objs := runPostRenderer(t, renderer, manifest)
+		for _, obj := range objs {
+			require.NotContains(t, obj.GetLabels(), "patched")
+		}
+	})
+
+	t.Run("invalid label selector", func(t *testing.T) {
+		renderer := NewPatchPostRenderer(Patch{
+			Target: PatchTarget{LabelSelector: "a in b"},
+		})
+
+		_, err := renderer.Run(bytes.NewBufferString(manifest))
+		require.ErrorContains(t, err, "invalid label selector 'a in b'")
+	})
+
+	t.Run("invalid patch", func(t *testing.T) {
+		renderer := NewPatchPostRenderer(Patch{
+			Target: PatchTarget{Kind: "ServiceAccount"},
+			Patch:  `:`,
+		})
+
+		_, err := renderer.Run(bytes.NewBufferString(manifest))
+		require.ErrorContains(t, err, "while patching ServiceAccount test-namespace/test-service-account: invalid patch")
+	})
+}
+
+func Test_getCachedAndCurrentManifest_postRenderer(t *testing.T) {
+	config := &Config{
+		Ctx:        context.Background(),
+		Cache:      NewInMemoryManifestCache(),
+		ManagerUID: "uid",
+	}
+	opts := &InstallOpts{
+		PostRenderer: NewPatchPostRenderer(Patch{
+			Target: PatchTarget{Kind: "ServiceAccount"},
+			Patch:  `{"metadata": {"annotations": {"patched": "true"}}}`,
+		}),
+	}
+
+	_, currentSpec, _, err := getCachedAndCurrentManifest(config, opts, fixManifestRenderFunc(testServiceAccount))
+	require.NoError(t, err)
+
+	objs, err := parseManifest(currentSpec.Manifest)
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	require.Equal(t, map[string]string{"patched": "true"}, objs[0].GetAnnotations())
+}
+
+func Test_getCachedAndCurrentManifest_postRendererChange(t *testing.T) {
+	config := &Config{
+		Ctx:        context.Background(),
+		Cache:      NewInMemoryManifestCache(),
+		ManagerUID: "uid",
+	}
+	firstRenderer := NewPatchPostRenderer(Patch{
+		Target: PatchTarget{Kind: "ServiceAccount"},
+		Patch:  `{"metadata": {"annotations": {"patched": "first"}}}`,
+	})
+	_, firstSpec, _, err := getCachedAndCurrentManifest(config, &InstallOpts{PostRenderer: firstRenderer}, fixManifestRenderFunc(testServiceAccount))
+	require.NoError(t, err)
+	require.NoError(t, config.Cache.Set(context.Background(), config.CacheKey, firstSpec))
+
+	t.Run("use cached manifest for the same post renderer", func(t *testing.T) {
+		_, currentSpec, rendered, err := getCachedAndCurrentManifest(config, &InstallOpts{PostRenderer: firstRenderer}, fixManifestRenderFunc(""))
+		require.NoError(t, err)
+		require.False(t, rendered)
+		require.Equal(t, firstSpec.Manifest, currentSpec.Manifest)
+	})
+
+	t.Run("render again when patches change", func(t *testing.T) {
+		secondRenderer := NewPatchPostRenderer(Patch{
+			Target: PatchTarget{Kind: "ServiceAccount"},
+			Patch:  `{"metadata": {"annotations": {"patched": "second"}}}`,
+		})
+		_, currentSpec, _, err := getCachedAndCurrentManifest(config, &InstallOpts{PostRenderer: secondRenderer}, fixManifestRenderFunc(testServiceAccount))
+		require.NoError(t, err)
+		require.NotEqual(t, firstSpec.PostRendererDigest, currentSpec.PostRendererDigest)
+
+		objs, err := parseManifest(currentSpec.Manifest)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"patched": "second"}, objs[0].GetAnnotations())
+	})
+
+	t.Run("always render with post renderer without digest", func(t *testing.T) {
+		require.NoError(t, config.Cache.Set(context.Background(), config.CacheKey, ContextManifest{ManagerUID: "uid", Manifest: testServiceAccount}))
+
+		_, currentSpec, rendered, err := getCachedAndCurrentManifest(config, &InstallOpts{PostRenderer: &noopPostRenderer{}}, fixManifestRenderFunc(testDeploy))
+		require.NoError(t, err)
+		require.True(t, rendered)
+		require.Equal(t, testDeploy, currentSpec.Manifest)
+	})
+}
+
+type noopPostRenderer struct{}
+
+func (r *noopPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	return renderedManifests, nil
+}
+
+func runPostRenderer(t *testing.T, renderer *patchPostRenderer, manifest string) []unstructured.Unstructured {
+	result, err := renderer.Run(bytes.NewBufferString(manifest))
+	require.NoError(t, err)
+
+	objs, err := parseManifest(result.String())
+	require.NoError(t, err)
+	return objs
+}
@@ -0,0 +1,95 @@
+package chart
+
+import (
+	"context"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type RenderOpts struct {
+	// Ctx is used to load the chart and values, context.Background() is used when not set
+	Ctx context.Context
+
+	// Values are sources of values merged in the given order before the flags
+	// sources reading values from the cluster (e.g. ValuesFromConfigMap) require the Cluster client
+	Values []ValuesSource
+
+	// Cluster is used only by values sources reading values from the cluster
+	Cluster Cluster
+
+	// PostRenderer modifies the whole rendered manifest before it's parsed
+	PostRenderer postrender.PostRenderer
+}
+
+// Render renders the chart without connecting to the cluster and returns objects in the installation order.
+// Capabilities pinned in the release are used for templates, otherwise Helm's defaults are used.
+// It can be used to test charts, e.g. to compare rendered objects with golden files.
+func Render(release Release, flags map[string]interface{}, opts RenderOpts) ([]unstructured.Unstructured, error) {
+	ctx := opts.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	values, err := mergeValues(ctx, opts.Cluster, opts.Values, flags)
+	if err != nil {
+		return nil, fmt.Errorf("could not merge values: %s", err.Error())
+	}
+
+	chart, err := loadChart(ctx, release)
+	if err != nil {
+		return nil, err
+	}
+
+	installAction, err := newClientOnlyInstallAction(release)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := installAction.RunWithContext(ctx, chart, values)
+	if err != nil {
+		return nil, fmt.Errorf("while templating chart: %s", err.Error())
+	}
+
+	manifest, err := postRenderManifest(opts.PostRenderer, rel.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	objs, err := parseManifest(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse chart manifest: %s", err.Error())
+	}
+
+	return objs, nil
+}
+
+func newClientOnlyInstallAction(release Release) (*action.Install, error) {
+	actionConfig := new(action.Configuration)
+	actionConfig.Log = func(string, ...interface{}) {}
+	actionConfig.Releases = storage.Init(driver.NewMemory())
+
+	action := action.NewInstall(actionConfig)
+	action.ReleaseName = release.Name
+	action.Namespace = release.Namespace
+	action.Replace = true
+	action.DryRun = true
+	action.ClientOnly = true
+
+	if release.Capabilities != nil {
+		// client only install uses default capabilities extended with the kube version and api versions
+		capabilities, err := release.Capabilities.toHelmCapabilities()
+		if err != nil {
+			return nil, err
+		}
+		action.KubeVersion = &capabilities.KubeVersion
+		action.APIVersions = chartutil.VersionSet(release.Capabilities.APIVersions)
+	}
+
+	return action, nil
+}
@@ -0,0 +1,121 @@
+package chart
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRender(t *testing.T) {
+	release := Release{
+		ChartPath: "testdata/test-chart",
+		Name:      "test-release",
+		Namespace: "test-namespace",
+	}
+
+	t.Run("render chart", func(t *testing.T) {
+		objs, err := Render(release, map[string]interface{}{"replicas": 2}, RenderOpts{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"ServiceAccount", "Deployment"}, kindsOf(objs))
+
+		deployment := objs[1]
+		require.Equal(t, "test-release", deployment.GetName())
+		require.Equal(t, "test-namespace", deployment.GetNamespace())
+		replicas, _, _ := unstructured.NestedFieldNoCopy(deployment.Object, "spec", "replicas")
+		require.Equal(t, 2, replicas)
+	})
+
+	t.Run("render chart with values", func(t *testing.T) {
+		objs, err := Render(release, nil, RenderOpts{
+			Values: []ValuesSource{
+				ValuesFromMap(map[string]interface{}{
+					"serviceAccount": map[string]interface{}{"create": false},
+				}),
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"Deployment"}, kindsOf(objs))
+	})
+
+	t.Run("render chart with pinned capabilities", func(t *testing.T) {
+		release := release
+		release.Capabilities = &Capabilities{
+			KubeVersion: "v1.33.1",
+			APIVersions: []string{"monitoring.coreos.com/v1/ServiceMonitor"},
+		}
+
+		objs, err := Render(release, nil, RenderOpts{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"ServiceAccount", "Deployment", "ServiceMonitor"}, kindsOf(objs))
+		require.Equal(t, "v1.33.1", objs[2].GetLabels()["kubeVersion"])
+	})
+
+	t.Run("render chart with post renderer", func(t *testing.T) {
+		objs, err := Render(release, nil, RenderOpts{
+			PostRenderer: NewPatchPostRenderer(Patch{
+				Target: PatchTarget{Kind: "Deployment"},
+				Patch:  `{"spec": {"replicas": 3}}`,
+			}),
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"ServiceAccount", "Deployment"}, kindsOf(objs))
+		replicas, _, _ := unstructured.NestedFieldNoCopy(objs[1].Object, "spec", "replicas")
+		require.Equal(t, 3, replicas)
+	})
+
+	t.Run("render chart with values from the cluster", func(t *testing.T) {
+		key := types.NamespacedName{Name: "test-values", Namespace: "test-namespace"}
+		c := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string]string{"values.yaml": "serviceAccount:\n  create: false\n"},
+		}).Build()
+
+		objs, err := Render(release, nil, RenderOpts{
+			Values:  []ValuesSource{ValuesFromConfigMap(key, "values.yaml")},
+			Cluster: Cluster{Client: c},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"Deployment"}, kindsOf(objs))
+	})
+
+	t.Run("values from the cluster without client", func(t *testing.T) {
+		key := types.NamespacedName{Name: "test-values", Namespace: "test-namespace"}
+
+		_, err := Render(release, nil, RenderOpts{
+			Values: []ValuesSource{
+				ValuesFromConfigMap(key, "values.yaml"),
+			},
+		})
+		require.ErrorContains(t, err, "cluster client is required to get values configmap test-namespace/test-values")
+
+		_, err = Render(release, nil, RenderOpts{
+			Values: []ValuesSource{
+				ValuesFromSecret(key, "values.yaml"),
+			},
+		})
+		require.ErrorContains(t, err, "cluster client is required to get values secret test-namespace/test-values")
+	})
+
+	t.Run("values do not match schema", func(t *testing.T) {
+		_, err := Render(release, map[string]interface{}{"replicas": "two"}, RenderOpts{})
+		require.ErrorContains(t, err, "while templating chart")
+	})
+
+	t.Run("chart not configured", func(t *testing.T) {
+		_, err := Render(Release{}, nil, RenderOpts{})
+		require.ErrorContains(t, err, "chart path or source is not configured")
+	})
+}
+
+func kindsOf(objs []unstructured.Unstructured) []string {
+	kinds := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		kinds = append(kinds, obj.GetKind())
+	}
+	return kinds
+}
@@ -0,0 +1,115 @@
+package chart
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ValueResolver adds flags based on the live cluster state, e.g. the cluster domain or installed CRDs.
+// Resolved flags are overridden by the CustomFlags and the chart is rendered again every time they change.
+type ValueResolver func(ctx context.Context, cluster Cluster, flags FlagsBuilder) error
+
+// ResolveConfigMapValue sets the flag to the value stored under the dataKey of the ConfigMap
+// the flag is not set if the ConfigMap or the dataKey does not exist
+func ResolveConfigMapValue(key types.NamespacedName, dataKey, flag string) ValueResolver {
+	return func(ctx context.Context, cluster Cluster, flags FlagsBuilder) error {
+		configMap := corev1.ConfigMap{}
+		if cluster.Client == nil {
+			return fmt.Errorf("cluster client is required to get configmap %s", key.String())
+		}
+
+		err := cluster.Client.Get(ctx, key, &configMap)
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("while getting configmap %s: %s", key.String(), err.Error())
+		}
+
+		// the value is set as it is, without parsing it like the --set argument
+		if value, ok := configMap.Data[dataKey]; ok {
+			flags.WithString(flag, value)
+		}
+		return nil
+	}
+}
+
+// ResolveCRDExists sets the flag to true if the CustomResourceDefinition with the given name exists
+// example: ResolveCRDExists("virtualservices.networking.istio.io", "istio.enabled")
+func ResolveCRDExists(crdName, flag string) ValueResolver {
+	return func(ctx context.Context, cluster Cluster, flags FlagsBuilder) error {
+		crd := metav1.PartialObjectMetadata{}
+		crd.SetGroupVersionKind(schema.GroupVersionKind{
+			Group:   "apiextensions.k8s.io",
+			Version: "v1",
+			Kind:    "CustomResourceDefinition",
+		})
+		if cluster.Client == nil {
+			return fmt.Errorf("cluster client is required to get crd %s", crdName)
+		}
+
+		err := cluster.Client.Get(ctx, types.NamespacedName{Name: crdName}, &crd)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("while getting crd %s: %s", crdName, err.Error())
+		}
+
+		flags.With(flag, err == nil)
+		return nil
+	}
+}
+
+// ResolveNodeArchitectures sets the flag to the sorted list of architectures of all cluster nodes
+func ResolveNodeArchitectures(flag string) ValueResolver {
+	return func(ctx context.Context, cluster Cluster, flags FlagsBuilder) error {
+		nodes := corev1.NodeList{}
+		if cluster.Client == nil {
+			return fmt.Errorf("cluster client is required to list nodes")
+		}
+
+		err := cluster.Client.List(ctx, &nodes)
+		if err != nil {
+			return fmt.Errorf("while listing nodes: %s", err.Error())
+		}
+
+		architectures := []string{}
+		for _, node := range nodes.Items {
+			arch, ok := node.Labels[corev1.LabelArchStable]
+			if ok && !slices.Contains(architectures, arch) {
+				architectures = append(architectures, arch)
+			}
+		}
+		slices.Sort(architectures)
+
+		flags.WithList(flag, architectures)
+		return nil
+	}
+}
+
+// resolveFlags runs all resolvers and returns their flags overridden by the customFlags
+func resolveFlags(config *Config, resolvers []ValueResolver, customFlags map[string]interface{}) (map[string]interface{}, error) {
+	if len(resolvers) == 0 {
+		return customFlags, nil
+	}
+
+	flags := NewFlagsBuilder()
+	for _, resolve := range resolvers {
+		err := resolve(config.Ctx, config.Cluster, flags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resolvedFlags, err := flags.Build()
+	if err != nil {
+		return nil, fmt.Errorf("while building resolved flags: %s", err.Error())
+	}
+
+	return coalesceValues(customFlags, resolvedFlags), nil
+}
@@ -0,0 +1,127 @@
+package chart
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_resolveFlags(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, apiextensionsv1.AddToScheme(scheme))
+
+	shootInfoKey := types.NamespacedName{Name: "shoot-info", Namespace: "kube-system"}
+	config := &Config{
+		Ctx: context.Background(),
+		Cluster: Cluster{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: shootInfoKey.Name, Namespace: shootInfoKey.Namespace},
+					Data:       map[string]string{"domain": "cluster.local", "hibernated": "false,true"},
+				},
+				&apiextensionsv1.CustomResourceDefinition{
+					ObjectMeta: metav1.ObjectMeta{Name: "virtualservices.networking.istio.io"},
+				},
+				fixNode("node-1", "arm64"),
+				fixNode("node-2", "amd64"),
+				fixNode("node-3", "arm64"),
+			).Build(),
+		},
+	}
+
+	t.Run("resolve flags", func(t *testing.T) {
+		flags, err := resolveFlags(config, []ValueResolver{
+			ResolveConfigMapValue(shootInfoKey, "domain", "global.domain"),
+			ResolveConfigMapValue(shootInfoKey, "missing", "global.missing"),
+			ResolveConfigMapValue(types.NamespacedName{Name: "missing", Namespace: "kube-system"}, "domain", "global.other"),
+			ResolveCRDExists("virtualservices.networking.istio.io", "istio.enabled"),
+			ResolveCRDExists("gateways.gateway.networking.k8s.io", "gateway.enabled"),
+			ResolveNodeArchitectures("global.architectures"),
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"global": map[string]interface{}{
+				"domain":        "cluster.local",
+				"architectures": []interface{}{"amd64", "arm64"},
+			},
+			"istio": map[string]interface{}{
+				"enabled": true,
+			},
+			"gateway": map[string]interface{}{
+				"enabled": false,
+			},
+		}, flags)
+	})
+
+	t.Run("keep configmap values as strings", func(t *testing.T) {
+		flags, err := resolveFlags(config, []ValueResolver{
+			ResolveConfigMapValue(shootInfoKey, "hibernated", "global.hibernated"),
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"global": map[string]interface{}{"hibernated": "false,true"},
+		}, flags)
+	})
+
+	t.Run("custom flags override resolved flags", func(t *testing.T) {
+		flags, err := resolveFlags(config, []ValueResolver{
+			ResolveConfigMapValue(shootInfoKey, "domain", "global.domain"),
+		}, map[string]interface{}{
+			"global": map[string]interface{}{"domain": "example.com"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"global": map[string]interface{}{"domain": "example.com"},
+		}, flags)
+	})
+
+	t.Run("no resolvers", func(t *testing.T) {
+		customFlags := map[string]interface{}{"replicas": 1}
+
+		flags, err := resolveFlags(config, nil, customFlags)
+		require.NoError(t, err)
+		require.Equal(t, customFlags, flags)
+	})
+
+	t.Run("missing cluster client", func(t *testing.T) {
+		noClientConfig := &Config{Ctx: context.Background()}
+
+		_, err := resolveFlags(noClientConfig, []ValueResolver{ResolveConfigMapValue(shootInfoKey, "domain", "global.domain")}, nil)
+		require.EqualError(t, err, "cluster client is required to get configmap kube-system/shoot-info")
+
+		_, err = resolveFlags(noClientConfig, []ValueResolver{ResolveCRDExists("virtualservices.networking.istio.io", "istio.enabled")}, nil)
+		require.EqualError(t, err, "cluster client is required to get crd virtualservices.networking.istio.io")
+
+		_, err = resolveFlags(noClientConfig, []ValueResolver{ResolveNodeArchitectures("global.architectures")}, nil)
+		require.EqualError(t, err, "cluster client is required to list nodes")
+	})
+
+	t.Run("resolver error", func(t *testing.T) {
+		testErr := errors.New("test error")
+		_, err := resolveFlags(config, []ValueResolver{
+			func(_ context.Context, _ Cluster, _ FlagsBuilder) error {
+				return testErr
+			},
+		}, nil)
+		require.ErrorIs(t, err, testErr)
+	})
+}
+
+func fixNode(name, arch string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{corev1.LabelArchStable: arch},
+		},
+	}
+}
@@ -0,0 +1,69 @@
+package chart
+
+import (
+	"fmt"
+
+	"github.com/kyma-project/manager-toolkit/installation/chart/action"
+)
+
+// Rollback re-applies the manifest of the given revision stored in the cache history
+// and removes objects which are not part of it. The rolled back manifest is stored as a new revision
+// together with flags, values and digests used to render it.
+// The next Install keeps the rolled back manifest only if it's called with the same inputs as the revision,
+// otherwise the chart is rendered again and the rollback is undone, so callers have to restore their inputs too.
+// PreActions can be passed to mutate resources the same way as during the installation
+func Rollback(config *Config, revision int, preActions ...action.PreApply) error {
+	cachedSpec, err := config.Cache.Get(config.Ctx, config.CacheKey)
+	if err != nil {
+		return fmt.Errorf("could not get manifest from cache: %s", err.Error())
+	}
+
+	target, found := findRevision(cachedSpec, revision)
+	if !found {
+		return fmt.Errorf("revision %d not found in the release history", revision)
+	}
+	if target.Outcome == RevisionFailed {
+		return fmt.Errorf("revision %d has failed and can't be restored", revision)
+	}
+
+	objs, unusedObjs, err := getObjectsToInstallAndRemove(cachedSpec.Manifest, target.Manifest)
+	if err != nil {
+		return err
+	}
+
+	targetSpec := ContextManifest{
+		ManagerUID:         target.ManagerUID,
+		CustomFlags:        target.CustomFlags,
+		Values:             target.Values,
+		Manifest:           target.Manifest,
+		ChartDigest:        target.ChartDigest,
+		PostRendererDigest: target.PostRendererDigest,
+		Subcharts:          target.Subcharts,
+		// hooks are not stored in the history, keep hooks of the deployed manifest to run them on uninstall
+		Hooks: cachedSpec.Hooks,
+	}
+
+	result := &InstallResult{}
+	_, err = updateObjects(config, objs, action.ContextPreApplies(preActions, nil), nil, cachedSpec.ApplyTimes, result)
+	if err != nil {
+		return recordFailedRevision(config, cachedSpec, targetSpec, err)
+	}
+
+	_, err = deleteObjects(config, unusedObjs)
+	if err != nil {
+		return err
+	}
+
+	targetSpec.ApplyTimes = result.applyTimes
+	return config.Cache.Set(config.Ctx, config.CacheKey, withDeployedRevision(config, cachedSpec, targetSpec))
+}
+
+func findRevision(spec ContextManifest, revision int) (ManifestRevision, bool) {
+	for _, r := range spec.Revisions() {
+		if r.Revision == revision {
+			return r, true
+		}
+	}
+
+	return ManifestRevision{}, false
+}
@@ -0,0 +1,104 @@
+package chart
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRollback(t *testing.T) {
+	testManifestKey := types.NamespacedName{
+		Name: "test", Namespace: "testnamespace",
+	}
+
+	t.Run("rollback to previous revision", func(t *testing.T) {
+		cache := NewInMemoryManifestCache()
+		_ = cache.Set(context.Background(), testManifestKey, ContextManifest{
+			ManagerUID: "uid-2",
+			Manifest:   fmt.Sprint(testCRD, separator, testDeploy),
+			Revision:   2,
+			History: []ManifestRevision{
+				{
+					Revision:           1,
+					ManagerUID:         "uid-1",
+					CustomFlags:        map[string]interface{}{"replicas": 1},
+					Values:             map[string]interface{}{"replicas": 1},
+					Manifest:           "",
+					PostRendererDigest: "digest-1",
+					Outcome:            RevisionSuperseded,
+				},
+			},
+		})
+		client := fake.NewClientBuilder().WithObjects(testDeployCR.DeepCopy()).WithObjects(testCRDObj.DeepCopy()).Build()
+		config := &Config{
+			Ctx:        context.Background(),
+			Log:        zap.NewNop().Sugar(),
+			Cache:      cache,
+			CacheKey:   testManifestKey,
+			MaxHistory: 5,
+			Cluster: Cluster{
+				Client: client,
+			},
+		}
+
+		err := Rollback(config, 1)
+		require.NoError(t, err)
+
+		deploymentList := appsv1.DeploymentList{}
+		require.NoError(t, client.List(context.Background(), &deploymentList))
+		require.Empty(t, deploymentList.Items)
+
+		spec, err := cache.Get(context.Background(), testManifestKey)
+		require.NoError(t, err)
+		require.Equal(t, 3, spec.Revision)
+		require.Equal(t, "uid-1", spec.ManagerUID)
+		require.Empty(t, spec.Manifest)
+		require.Equal(t, map[string]interface{}{"replicas": 1}, spec.CustomFlags)
+		require.Equal(t, map[string]interface{}{"replicas": 1}, spec.Values)
+		require.Equal(t, "digest-1", spec.PostRendererDigest)
+		require.Len(t, spec.History, 2)
+		require.Equal(t, 2, spec.History[1].Revision)
+		require.Equal(t, RevisionSuperseded, spec.History[1].Outcome)
+	})
+
+	t.Run("revision not found", func(t *testing.T) {
+		cache := NewInMemoryManifestCache()
+		_ = cache.Set(context.Background(), testManifestKey, ContextManifest{
+			Manifest: testDeploy,
+			Revision: 1,
+		})
+		config := &Config{
+			Ctx:      context.Background(),
+			Cache:    cache,
+			CacheKey: testManifestKey,
+		}
+
+		err := Rollback(config, 7)
+		require.ErrorContains(t, err, "revision 7 not found")
+	})
+
+	t.Run("failed revision can't be restored", func(t *testing.T) {
+		cache := NewInMemoryManifestCache()
+		_ = cache.Set(context.Background(), testManifestKey, ContextManifest{
+			Manifest: testDeploy,
+			Revision: 1,
+			History: []ManifestRevision{
+				{Revision: 2, Manifest: testCRD, Outcome: RevisionFailed},
+			},
+		})
+		config := &Config{
+			Ctx:      context.Background(),
+			Cache:    cache,
+			CacheKey: testManifestKey,
+		}
+
+		err := Rollback(config, 2)
+		require.ErrorContains(t, err, "revision 2 has failed")
+	})
+}
@@ -0,0 +1,279 @@
+package chart
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+var (
+	_ ChartSource = (*dirChartSource)(nil)
+	_ ChartSource = (*fsChartSource)(nil)
+	_ ChartSource = (*archiveChartSource)(nil)
+	_ ChartSource = (*ociChartSource)(nil)
+
+	_ RegistryClient = (*helmRegistryClient)(nil)
+)
+
+// ChartSource provides the chart to render and the digest identifying its content.
+// The digest is stored in the cache and the chart is rendered again every time it changes.
+type ChartSource interface {
+	Load(context.Context) (*chart.Chart, error)
+	Digest(context.Context) (string, error)
+}
+
+type chartDigestKey struct{}
+
+// withChartDigest returns the context passing the digest resolved for the installation to the Load
+func withChartDigest(ctx context.Context, digest string) context.Context {
+	return context.WithValue(ctx, chartDigestKey{}, digest)
+}
+
+// chartDigestFromContext returns the digest passed by withChartDigest or an empty string
+func chartDigestFromContext(ctx context.Context) string {
+	digest, _ := ctx.Value(chartDigestKey{}).(string)
+	return digest
+}
+
+// dirChartSource provides the chart from the local directory.
+type dirChartSource struct {
+	path string
+}
+
+// NewDirChartSource returns a new instance of dirChartSource.
+func NewDirChartSource(path string) *dirChartSource {
+	return &dirChartSource{
+		path: path,
+	}
+}
+
+// Load loads the chart from the directory.
+func (s *dirChartSource) Load(_ context.Context) (*chart.Chart, error) {
+	ch, err := loader.Load(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("while loading chart from path '%s': %s", s.path, err.Error())
+	}
+
+	return ch, nil
+}
+
+// Digest returns hash of all files inside the directory.
+func (s *dirChartSource) Digest(_ context.Context) (string, error) {
+	return chartDirDigest(s.path)
+}
+
+// fsChartSource provides the chart from the fs.FS, e.g. embedded into the manager binary using embed.FS.
+type fsChartSource struct {
+	fsys fs.FS
+	dir  string
+}
+
+// NewFSChartSource returns a new instance of fsChartSource for the chart located in the dir of the fsys.
+func NewFSChartSource(fsys fs.FS, dir string) *fsChartSource {
+	return &fsChartSource{
+		fsys: fsys,
+		dir:  dir,
+	}
+}
+
+// Load loads the chart from the file system.
+func (s *fsChartSource) Load(_ context.Context) (*chart.Chart, error) {
+	chartFS, err := fs.Sub(s.fsys, s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("while loading chart from fs path '%s': %s", s.dir, err.Error())
+	}
+
+	files := []*loader.BufferedFile{}
+	err = walkFiles(chartFS, func(path string) error {
+		data, err := fs.ReadFile(chartFS, path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, &loader.BufferedFile{Name: path, Data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while loading chart from fs path '%s': %s", s.dir, err.Error())
+	}
+
+	ch, err := loader.LoadFiles(files)
+	if err != nil {
+		return nil, fmt.Errorf("while loading chart from fs path '%s': %s", s.dir, err.Error())
+	}
+
+	return ch, nil
+}
+
+// Digest returns hash of all files inside the chart directory.
+func (s *fsChartSource) Digest(_ context.Context) (string, error) {
+	chartFS, err := fs.Sub(s.fsys, s.dir)
+	if err != nil {
+		return "", fmt.Errorf("while calculating chart digest: %s", err.Error())
+	}
+
+	return fsDigest(chartFS)
+}
+
+// archiveChartSource provides the chart from the local .tgz archive.
+type archiveChartSource struct {
+	path string
+}
+
+// NewArchiveChartSource returns a new instance of archiveChartSource.
+func NewArchiveChartSource(path string) *archiveChartSource {
+	return &archiveChartSource{
+		path: path,
+	}
+}
+
+// Load loads the chart from the archive.
+func (s *archiveChartSource) Load(_ context.Context) (*chart.Chart, error) {
+	ch, err := loader.LoadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("while loading chart from archive '%s': %s", s.path, err.Error())
+	}
+
+	return ch, nil
+}
+
+// Digest returns hash of the archive.
+func (s *archiveChartSource) Digest(_ context.Context) (string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", fmt.Errorf("while calculating chart digest: %s", err.Error())
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data)), nil
+}
+
+// RegistryClient resolves and pulls charts from the OCI registry.
+type RegistryClient interface {
+	// Resolve returns digest of the chart manifest for the given reference
+	Resolve(ctx context.Context, ref string) (string, error)
+	// Pull returns the chart archive and digest of the chart manifest for the given reference
+	Pull(ctx context.Context, ref string) ([]byte, string, error)
+}
+
+// ociChartSource provides the chart stored in the OCI registry.
+//
+// The pulled archive is kept in memory and pulled again only when the reference resolves to other digest.
+// The installation passes the digest resolved by the Digest to the Load in the context, so the tag is resolved
+// once per installation and the chart is pulled by the digest.
+type ociChartSource struct {
+	ref    string
+	client RegistryClient
+
+	mu      sync.Mutex
+	digest  string
+	archive []byte
+}
+
+// NewOCIChartSource returns a new instance of ociChartSource for the reference, e.g. "registry.example.com/charts/my-chart:1.0.0".
+func NewOCIChartSource(ref string, client RegistryClient) *ociChartSource {
+	return &ociChartSource{
+		ref:    ref,
+		client: client,
+	}
+}
+
+// Load pulls (if needed) and loads the chart.
+func (s *ociChartSource) Load(ctx context.Context) (*chart.Chart, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	digest := chartDigestFromContext(ctx)
+	if digest == "" {
+		var err error
+		digest, err = s.resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.digest != digest || s.archive == nil {
+		archive, pulledDigest, err := s.client.Pull(ctx, digestRef(s.ref, digest))
+		if err != nil {
+			return nil, fmt.Errorf("while pulling chart '%s': %s", s.ref, err.Error())
+		}
+
+		s.digest = pulledDigest
+		s.archive = archive
+	}
+
+	ch, err := loader.LoadArchive(bytes.NewReader(s.archive))
+	if err != nil {
+		return nil, fmt.Errorf("while loading chart '%s': %s", s.ref, err.Error())
+	}
+
+	return ch, nil
+}
+
+// Digest resolves the reference to the chart manifest digest.
+func (s *ociChartSource) Digest(ctx context.Context) (string, error) {
+	return s.resolve(ctx)
+}
+
+func (s *ociChartSource) resolve(ctx context.Context) (string, error) {
+	digest, err := s.client.Resolve(ctx, s.ref)
+	if err != nil {
+		return "", fmt.Errorf("while resolving chart '%s': %s", s.ref, err.Error())
+	}
+
+	return digest, nil
+}
+
+// digestRef replaces the tag or digest of the reference with the given digest
+// e.g. "registry.example.com/charts/my-chart:1.0.0" becomes "registry.example.com/charts/my-chart@sha256:..."
+func digestRef(ref, digest string) string {
+	name := ref
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+
+	return name + "@" + digest
+}
+
+// helmRegistryClient adapts the Helm registry client to the RegistryClient interface.
+type helmRegistryClient struct {
+	client *registry.Client
+}
+
+// NewHelmRegistryClient returns a new instance of helmRegistryClient.
+func NewHelmRegistryClient(client *registry.Client) *helmRegistryClient {
+	return &helmRegistryClient{
+		client: client,
+	}
+}
+
+// Resolve returns digest of the chart manifest for the given reference.
+func (c *helmRegistryClient) Resolve(_ context.Context, ref string) (string, error) {
+	desc, err := c.client.Resolve(ref)
+	if err != nil {
+		return "", err
+	}
+
+	return desc.Digest.String(), nil
+}
+
+// Pull returns the chart archive and digest of the chart manifest for the given reference.
+func (c *helmRegistryClient) Pull(_ context.Context, ref string) ([]byte, string, error) {
+	result, err := c.client.Pull(ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return result.Chart.Data, result.Manifest.Digest, nil
+}
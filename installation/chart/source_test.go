@@ -0,0 +1,221 @@
+package chart
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+//go:embed testdata/test-chart
+var testChartFS embed.FS
+
+type fakeRegistryClient struct {
+	// archives are stored by digests
+	archives   map[string][]byte
+	digests    map[string]string
+	resolves   int
+	pulledRefs []string
+}
+
+func (c *fakeRegistryClient) Resolve(_ context.Context, ref string) (string, error) {
+	c.resolves++
+	digest, ok := c.digests[ref]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return digest, nil
+}
+
+func (c *fakeRegistryClient) Pull(_ context.Context, ref string) ([]byte, string, error) {
+	c.pulledRefs = append(c.pulledRefs, ref)
+	digest := ref[strings.LastIndex(ref, "@")+1:]
+	archive, ok := c.archives[digest]
+	if !ok {
+		return nil, "", errors.New("not found")
+	}
+	return archive, digest, nil
+}
+
+func TestDirChartSource(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("load chart", func(t *testing.T) {
+		source := NewDirChartSource("testdata/test-chart")
+
+		ch, err := source.Load(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "test-chart", ch.Name())
+
+		digest, err := source.Digest(ctx)
+		require.NoError(t, err)
+		require.NotEmpty(t, digest)
+	})
+
+	t.Run("chart not found", func(t *testing.T) {
+		_, err := NewDirChartSource("testdata/not-existing-chart").Load(ctx)
+		require.ErrorContains(t, err, "while loading chart from path 'testdata/not-existing-chart'")
+	})
+}
+
+func TestFSChartSource(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("load embedded chart", func(t *testing.T) {
+		source := NewFSChartSource(testChartFS, "testdata/test-chart")
+
+		ch, err := source.Load(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "test-chart", ch.Name())
+		require.Len(t, ch.Templates, 3)
+
+		digest, err := source.Digest(ctx)
+		require.NoError(t, err)
+		dirDigest, err := NewDirChartSource("testdata/test-chart").Digest(ctx)
+		require.NoError(t, err)
+		require.Equal(t, dirDigest, digest)
+	})
+
+	t.Run("chart not found", func(t *testing.T) {
+		_, err := NewFSChartSource(testChartFS, "testdata/not-existing-chart").Load(ctx)
+		require.Error(t, err)
+	})
+}
+
+func TestArchiveChartSource(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("load chart archive", func(t *testing.T) {
+		source := NewArchiveChartSource(fixChartArchive(t))
+
+		ch, err := source.Load(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "test-chart", ch.Name())
+
+		digest, err := source.Digest(ctx)
+		require.NoError(t, err)
+		require.Regexp(t, "^sha256:[0-9a-f]{64}$", digest)
+	})
+
+	t.Run("archive not found", func(t *testing.T) {
+		source := NewArchiveChartSource("testdata/not-existing-chart.tgz")
+
+		_, err := source.Load(ctx)
+		require.Error(t, err)
+		_, err = source.Digest(ctx)
+		require.Error(t, err)
+	})
+}
+
+func TestOCIChartSource(t *testing.T) {
+	ctx := context.Background()
+	ref := "registry.local/charts/test-chart:0.1.0"
+
+	archive, err := os.ReadFile(fixChartArchive(t))
+	require.NoError(t, err)
+
+	t.Run("pull chart only when digest changes", func(t *testing.T) {
+		client := &fakeRegistryClient{
+			archives: map[string][]byte{"sha256:1": archive, "sha256:2": archive},
+			digests:  map[string]string{ref: "sha256:1"},
+		}
+		source := NewOCIChartSource(ref, client)
+
+		digest, err := source.Digest(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "sha256:1", digest)
+
+		for range 2 {
+			ch, err := source.Load(ctx)
+			require.NoError(t, err)
+			require.Equal(t, "test-chart", ch.Name())
+		}
+		require.Equal(t, []string{"registry.local/charts/test-chart@sha256:1"}, client.pulledRefs)
+
+		client.digests[ref] = "sha256:2"
+		_, err = source.Load(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"registry.local/charts/test-chart@sha256:1",
+			"registry.local/charts/test-chart@sha256:2",
+		}, client.pulledRefs)
+	})
+
+	t.Run("resolve the tag once for digest and load", func(t *testing.T) {
+		client := &fakeRegistryClient{
+			archives: map[string][]byte{"sha256:1": archive},
+			digests:  map[string]string{ref: "sha256:1"},
+		}
+		source := NewOCIChartSource(ref, client)
+
+		digest, err := source.Digest(ctx)
+		require.NoError(t, err)
+		_, err = source.Load(withChartDigest(ctx, digest))
+		require.NoError(t, err)
+		require.Equal(t, 1, client.resolves)
+	})
+
+	t.Run("load the digest passed by the installation", func(t *testing.T) {
+		client := &fakeRegistryClient{
+			archives: map[string][]byte{"sha256:1": archive, "sha256:2": archive},
+			digests:  map[string]string{ref: "sha256:2"},
+		}
+		source := NewOCIChartSource(ref, client)
+
+		_, err := source.Load(withChartDigest(ctx, "sha256:1"))
+		require.NoError(t, err)
+		_, err = source.Load(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"registry.local/charts/test-chart@sha256:1",
+			"registry.local/charts/test-chart@sha256:2",
+		}, client.pulledRefs)
+	})
+
+	t.Run("chart not found", func(t *testing.T) {
+		source := NewOCIChartSource(ref, &fakeRegistryClient{})
+
+		_, err := source.Load(ctx)
+		require.ErrorContains(t, err, "while resolving chart")
+	})
+}
+
+func Test_digestRef(t *testing.T) {
+	require.Equal(t, "registry.local/charts/test-chart@sha256:1", digestRef("registry.local/charts/test-chart:0.1.0", "sha256:1"))
+	require.Equal(t, "registry.local:5000/test-chart@sha256:1", digestRef("registry.local:5000/test-chart", "sha256:1"))
+	require.Equal(t, "oci://registry.local/test-chart@sha256:2", digestRef("oci://registry.local/test-chart@sha256:1", "sha256:2"))
+}
+
+func Test_chartSource(t *testing.T) {
+	t.Run("prefer source", func(t *testing.T) {
+		source := NewFSChartSource(testChartFS, "testdata/test-chart")
+		require.Equal(t, source, Release{ChartPath: "testdata/test-chart", Source: source}.chartSource())
+	})
+
+	t.Run("local directory", func(t *testing.T) {
+		require.IsType(t, &dirChartSource{}, Release{ChartPath: "testdata/test-chart"}.chartSource())
+	})
+
+	t.Run("local archive", func(t *testing.T) {
+		require.IsType(t, &archiveChartSource{}, Release{ChartPath: "test-chart-0.1.0.tgz"}.chartSource())
+	})
+
+	t.Run("no chart", func(t *testing.T) {
+		require.Nil(t, Release{}.chartSource())
+	})
+}
+
+func fixChartArchive(t *testing.T) string {
+	ch, err := NewDirChartSource("testdata/test-chart").Load(context.Background())
+	require.NoError(t, err)
+
+	path, err := chartutil.Save(ch, t.TempDir())
+	require.NoError(t, err)
+
+	return path
+}
@@ -0,0 +1,61 @@
+package chart
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const tracerName = "github.com/kyma-project/manager-toolkit/installation/chart"
+
+// startSpan starts the span as a child of the span from the config context
+// it returns copy of the config with the context containing the new span so nested calls are traced too
+func startSpan(config *Config, name string, attrs ...attribute.KeyValue) (*Config, trace.Span) {
+	tracerProvider := config.TracerProvider
+	if tracerProvider == nil {
+		// the global provider is a no-op one until the application configures it
+		tracerProvider = otel.GetTracerProvider()
+	}
+
+	ctx := config.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, span := tracerProvider.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+	spanConfig := *config
+	spanConfig.Ctx = ctx
+	return &spanConfig, span
+}
+
+// endSpan records the error (if any) on the span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func releaseAttributes(config *Config) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("chart.manager", config.ManagerName),
+		attribute.String("chart.release.name", config.Release.Name),
+		attribute.String("chart.release.namespace", config.Release.Namespace),
+	}
+}
+
+func objectAttributes(u *unstructured.Unstructured) []attribute.KeyValue {
+	gvk := u.GroupVersionKind()
+	return []attribute.KeyValue{
+		attribute.String("k8s.object.group", gvk.Group),
+		attribute.String("k8s.object.version", gvk.Version),
+		attribute.String("k8s.object.kind", gvk.Kind),
+		attribute.String("k8s.object.namespace", u.GetNamespace()),
+		attribute.String("k8s.object.name", u.GetName()),
+	}
+}
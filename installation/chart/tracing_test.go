@@ -0,0 +1,122 @@
+package chart
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kyma-project/manager-toolkit/installation/base/resource"
+	"github.com/kyma-project/manager-toolkit/installation/chart/action"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_install_tracing(t *testing.T) {
+	t.Run("trace installation", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		config := fixConfig(t, withCachedManifest(testCRD), withObjects(testCRDObj.DeepCopy()), withSpanRecorder(recorder))
+
+		_, err := install(config, &InstallOpts{}, fixManifestRenderFunc(fmt.Sprint(testServiceAccount, separator, testDeploy)))
+		require.NoError(t, err)
+
+		spans := recorder.Ended()
+		require.Equal(t, []string{
+			"chart.cache.get",
+			"chart.render",
+			"chart.apply.object",
+			"chart.apply.object",
+			"chart.apply",
+			"chart.prune",
+			"chart.cache.set",
+			"chart.install",
+		}, spanNames(spans))
+
+		installSpan := spans[len(spans)-1]
+		require.Contains(t, installSpan.Attributes(), attribute.String("chart.release.name", "test-release"))
+		require.Contains(t, installSpan.Attributes(), attribute.Bool("chart.rendered", true))
+		for _, span := range []sdktrace.ReadOnlySpan{spans[0], spans[1], spans[4], spans[5], spans[6]} {
+			require.Equal(t, installSpan.SpanContext().SpanID(), span.Parent().SpanID(), span.Name())
+		}
+
+		// objects are traced as children of the apply span
+		require.Equal(t, spans[4].SpanContext().SpanID(), spans[2].Parent().SpanID())
+		require.Contains(t, spans[2].Attributes(), attribute.String("k8s.object.kind", "ServiceAccount"))
+		require.Contains(t, spans[3].Attributes(), attribute.String("k8s.object.group", "apps"))
+		require.Contains(t, spans[3].Attributes(), attribute.String("k8s.object.name", "test-deploy"))
+		require.Contains(t, spans[5].Attributes(), attribute.Int("chart.objects", 1))
+	})
+
+	t.Run("record errors", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		config := fixConfig(t, withCachedManifest(testCRD), withObjects(testCRDObj.DeepCopy()), withSpanRecorder(recorder))
+		opts := &InstallOpts{
+			PreActions: []action.PreApply{
+				action.PreApplyWithPredicate(func(u *unstructured.Unstructured) error {
+					return errors.New("test error")
+				}, resource.IsDeployment),
+			},
+		}
+
+		_, err := install(config, opts, fixManifestRenderFunc(testDeploy))
+		require.Error(t, err)
+
+		spans := recorder.Ended()
+		require.Equal(t, []string{"chart.cache.get", "chart.render", "chart.apply.object", "chart.apply", "chart.install"}, spanNames(spans))
+		for _, span := range spans[2:] {
+			require.Equal(t, codes.Error, span.Status().Code, span.Name())
+			require.Equal(t, "test error", span.Status().Description)
+			require.Len(t, span.Events(), 1)
+			require.Equal(t, "exception", span.Events()[0].Name)
+		}
+	})
+}
+
+func Test_Uninstall_tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	config := fixConfig(t, withCachedManifest(testCRD), withObjects(testCRDObj.DeepCopy()), withSpanRecorder(recorder))
+
+	done, err := Uninstall(config, &UninstallOpts{})
+	require.NoError(t, err)
+	require.False(t, done)
+
+	spans := recorder.Ended()
+	require.Equal(t, []string{"chart.uninstall.stage", "chart.uninstall.stage", "chart.uninstall"}, spanNames(spans))
+	require.Contains(t, spans[0].Attributes(), attribute.String("chart.stage", "resources"))
+	require.Contains(t, spans[1].Attributes(), attribute.String("chart.stage", "remaining"))
+	require.Contains(t, spans[2].Attributes(), attribute.Bool("chart.done", false))
+	require.Equal(t, spans[2].SpanContext().SpanID(), spans[1].Parent().SpanID())
+}
+
+func Test_Verify_tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	config := fixConfig(t, withSpanRecorder(recorder), withCachedManifest(testDeploy), withObjects(testDeployNotReadyCR.DeepCopy()))
+
+	_, err := Verify(config)
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Equal(t, []string{"chart.verify"}, spanNames(spans))
+	require.Contains(t, spans[0].Attributes(), attribute.Bool("chart.ready", false))
+	require.Contains(t, spans[0].Attributes(), attribute.String("chart.reason", DeploymentVerificationProcessing))
+}
+
+func Test_startSpan(t *testing.T) {
+	t.Run("use global no-op provider by default", func(t *testing.T) {
+		spanConfig, span := startSpan(&Config{}, "test")
+		require.False(t, span.SpanContext().IsValid())
+		require.NotNil(t, spanConfig.Ctx)
+		span.End()
+	})
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	return names
+}
@@ -2,66 +2,385 @@ package chart
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/kyma-project/manager-toolkit/installation/base/resource"
 	"github.com/kyma-project/manager-toolkit/installation/chart/action"
 
+	"go.opentelemetry.io/otel/attribute"
+	"helm.sh/helm/v3/pkg/release"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// UninstallStage groups resources deleted together
+// the next stage is started when all resources of the previous one are deleted
+type UninstallStage struct {
+	Name      string
+	Predicate resource.Predicate
+}
+
 type UninstallOpts struct {
 	// Predicate can be used to uninstall certain resources before others
 	// other resources will be uninstalled after these are done
+	// it's a shorthand for the first of the Stages
 	UninstallFirst resource.Predicate
 
+	// Stages define the order of the uninstallation, resources are assigned to the first matching stage
+	// resources not matching any stage are uninstalled at the end
+	// by default resources are uninstalled in the reverse order of the installation (CRDs and PriorityClasses last)
+	Stages []UninstallStage
+
+	// FinalizerTimeout is the time after which objects pending deletion are reported as stuck
+	// in the UninstallResult listing their finalizers, it's disabled when set to 0
+	FinalizerTimeout time.Duration
+
+	// ForceRemoveFinalizers selects stuck objects whose finalizers are removed after the FinalizerTimeout
+	// e.g. when the controller handling them has been already uninstalled
+	ForceRemoveFinalizers resource.Predicate
+
+	// PreActions to be executed before uninstalling each resource which exists and is not being deleted yet
+	// the resource is not deleted until all of them are done, e.g. to scale down or take a backup
+	PreActions []action.PreUninstall
+
 	// PostActions to be executed after uninstalling each resource
 	// can be used for cleanup tasks
 	PostActions []action.PostUninstall
+
+	// ContextPreActions and ContextPostActions are context-aware variants of the actions above
+	// they are executed after the corresponding actions without the context
+	ContextPreActions  []action.ContextPreUninstall
+	ContextPostActions []action.ContextPostUninstall
+}
+
+// UninstallObject describes the state of the object during the uninstallation
+type UninstallObject struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Finalizers and DeletionTimestamp are set for objects pending deletion
+	Finalizers        []string
+	DeletionTimestamp *metav1.Time
+	// Error is set for objects which could not be deleted
+	Error string
+}
+
+// UninstallResult describes the progress of the uninstallation
+// objects are listed only for the stages which have been already started
+type UninstallResult struct {
+	// Done indicates whether all resources have been uninstalled
+	Done bool
+	// Stage is the name of the stage in progress, e.g. the uninstall stage or the hook event
+	Stage string
+	// Deleted objects whose deletion has been requested
+	Deleted []UninstallObject
+	// Pending objects are already being deleted, e.g. they are blocked by finalizers
+	Pending []UninstallObject
+	// NotFound objects don't exist in the cluster anymore
+	NotFound []UninstallObject
+	// Stuck objects are pending deletion longer than the FinalizerTimeout
+	// they're listed also as Pending
+	Stuck []StuckObject
+	// FinalizersRemoved objects were stuck and their finalizers have been removed, so they are deleted right away
+	FinalizersRemoved []UninstallObject
+	// Kept objects are not deleted until their pre uninstall actions are done
+	Kept []UninstallObject
+	// Failed objects could not be deleted
+	Failed []UninstallObject
+}
+
+// Summary describes objects the uninstallation is waiting for
+// e.g. "waiting for stage 'resources': 3 PersistentVolumeClaim, 1 Deployment to be deleted"
+func (r *UninstallResult) Summary() string {
+	if r.Done {
+		return "uninstalled"
+	}
+
+	kinds := []string{}
+	counts := map[string]int{}
+	for _, obj := range append(append([]UninstallObject{}, r.Deleted...), r.Pending...) {
+		if counts[obj.Kind] == 0 {
+			kinds = append(kinds, obj.Kind)
+		}
+		counts[obj.Kind]++
+	}
+
+	waitingFor := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		waitingFor = append(waitingFor, fmt.Sprintf("%d %s", counts[kind], kind))
+	}
+	if len(waitingFor) == 0 {
+		return fmt.Sprintf("waiting for stage '%s'", r.Stage)
+	}
+
+	return fmt.Sprintf("waiting for stage '%s': %s to be deleted", r.Stage, strings.Join(waitingFor, ", "))
 }
 
 // Uninstall uninstalls all resources defined in the chart manifest stored in the cache
+// it returns true when all resources are uninstalled
+// objects stuck longer than the FinalizerTimeout are returned as the StuckObjectsError
 func Uninstall(config *Config, opts *UninstallOpts) (bool, error) {
-	done, err := uninstall(config, opts)
-	if err != nil {
-		return done, err
+	result, err := UninstallWithResult(config, opts)
+	if err == nil && len(result.Stuck) > 0 {
+		return false, &StuckObjectsError{Objects: result.Stuck}
 	}
 
-	if !done {
+	return result.Done, err
+}
+
+// UninstallWithResult works like the Uninstall but returns the result describing the progress of the uninstallation
+// the result is returned also with the error, stuck objects are listed in the result instead of the error
+func UninstallWithResult(config *Config, opts *UninstallOpts) (*UninstallResult, error) {
+	result := &UninstallResult{}
+	start := time.Now()
+	spanConfig, span := startSpan(config, "chart.uninstall", releaseAttributes(config)...)
+	err := uninstallAndCleanCache(spanConfig, opts, result)
+	span.SetAttributes(attribute.Bool("chart.done", result.Done), attribute.String("chart.stage", result.Stage))
+	endSpan(span, err)
+	observeUninstall(config, result, time.Since(start))
+	recordUninstallEvent(config, result, err)
+	return result, err
+}
+
+func uninstallAndCleanCache(config *Config, opts *UninstallOpts, result *UninstallResult) error {
+	done, err := uninstall(config, opts, result)
+	if err != nil || !done {
 		// not all resources are deleted yet
-		return done, nil
+		return err
 	}
 
 	// all resources are deleted, remove the cache entry
-	return true, config.Cache.Delete(config.Ctx, config.CacheKey)
+	result.Done = true
+	result.Stage = ""
+	return config.Cache.Delete(config.Ctx, config.CacheKey)
 }
 
-func uninstall(config *Config, opts *UninstallOpts) (bool, error) {
+func uninstall(config *Config, opts *UninstallOpts, result *UninstallResult) (bool, error) {
 	spec, err := config.Cache.Get(config.Ctx, config.CacheKey)
 	if err != nil {
 		return false, fmt.Errorf("could not render manifest from chart: %s", err.Error())
 	}
 
+	result.Stage = string(release.HookPreDelete)
+	done, err := runPreDeleteHooks(config, spec)
+	if err != nil || !done {
+		return done, err
+	}
+
 	manifestObjs, err := parseManifest(spec.Manifest)
 	if err != nil {
 		return false, fmt.Errorf("could not parse chart manifest: %s", err.Error())
 	}
 
-	firstToUninstall, objs := resource.SplitByPredicates(manifestObjs, opts.UninstallFirst)
+	preActions := action.ContextPreUninstalls(opts.PreActions, opts.ContextPreActions)
+	stages := uninstallStages(opts)
+	for i, stageObjs := range splitIntoStages(manifestObjs, stages) {
+		result.Stage = stages[i].Name
+		stageConfig, span := startSpan(config, "chart.uninstall.stage",
+			attribute.String("chart.stage", stages[i].Name), attribute.Int("chart.objects", len(stageObjs)))
+		done, err = uninstallObjects(stageConfig, opts, stageObjs, preActions, result)
+		endSpan(span, err)
+		if err != nil || !done {
+			config.Log.Debugf("waiting for uninstall stage '%s'", stages[i].Name)
+			return false, err
+		}
+	}
 
-	// delete first to uninstall objs
-	done, err := deleteObjects(config, firstToUninstall)
+	// fire post uninstall actions for all objs
+	result.Stage = "post-uninstall"
+	done, err = firePostUninstallForObjs(config, opts, manifestObjs)
 	if err != nil || !done {
 		return done, err
 	}
 
-	// delete remaining objs
-	done, err = deleteObjects(config, objs)
+	result.Stage = string(release.HookPostDelete)
+	return runHooks(config, spec, release.HookPostDelete)
+}
+
+// runPreDeleteHooks runs pre-delete hooks once and marks post-delete hooks as pending in the cache
+// so hooks are not executed again during the following reconciliations
+func runPreDeleteHooks(config *Config, spec ContextManifest) (bool, error) {
+	if len(spec.Hooks) == 0 || spec.PendingHooks == release.HookPostDelete {
+		return true, nil
+	}
+
+	done, err := runHooks(config, spec, release.HookPreDelete)
 	if err != nil || !done {
 		return done, err
 	}
 
-	// fire post uninstall actions for all objs
-	return firePostUninstallForObjs(opts, manifestObjs)
+	spec.PendingHooks = release.HookPostDelete
+	return true, config.Cache.Set(config.Ctx, config.CacheKey, spec)
+}
+
+const remainingUninstallStage = "remaining"
+
+// uninstallStages returns stages with the UninstallFirst shorthand and the final stage for remaining resources
+func uninstallStages(opts *UninstallOpts) []UninstallStage {
+	stages := []UninstallStage{}
+	if opts.UninstallFirst != nil {
+		stages = append(stages, UninstallStage{Name: "first", Predicate: opts.UninstallFirst})
+	}
+
+	stages = append(stages, opts.Stages...)
+	if len(opts.Stages) == 0 {
+		// resources installed first are removed at the end
+		stages = append(stages, UninstallStage{
+			Name: "resources",
+			Predicate: func(u unstructured.Unstructured) bool {
+				return !isInstalledFirst(u)
+			},
+		})
+	}
+
+	return append(stages, UninstallStage{Name: remainingUninstallStage})
+}
+
+// splitIntoStages assigns objects to the first matching stage keeping the reverse order of the installation
+func splitIntoStages(objs []unstructured.Unstructured, stages []UninstallStage) [][]unstructured.Unstructured {
+	result := make([][]unstructured.Unstructured, len(stages))
+	for i := len(objs) - 1; i >= 0; i-- {
+		stage := len(stages) - 1
+		for j := range stages {
+			if stages[j].Predicate != nil && stages[j].Predicate(objs[i]) {
+				stage = j
+				break
+			}
+		}
+		result[stage] = append(result[stage], objs[i])
+	}
+
+	return result
+}
+
+// uninstallObjects requests deletion of objects and records their state in the result
+// it returns an error listing failed objects after going through all of them
+func uninstallObjects(config *Config, opts *UninstallOpts, objs []unstructured.Unstructured, preUninstallFuncs []action.ContextPreUninstall, result *UninstallResult) (bool, error) {
+	done := true
+	failed := []string{}
+	for i := range objs {
+		u := objs[i]
+		obj := UninstallObject{Kind: u.GetKind(), Namespace: u.GetNamespace(), Name: u.GetName()}
+
+		live, err := getLiveObject(config, &u)
+		if err != nil {
+			done = false
+			obj.Error = err.Error()
+			result.Failed = append(result.Failed, obj)
+			failed = append(failed, obj.Error)
+			continue
+		}
+		if live == nil {
+			config.Log.Debugf("deletion skipped for %s %s", u.GetKind(), u.GetName())
+			result.NotFound = append(result.NotFound, obj)
+			continue
+		}
+
+		if live.GetDeletionTimestamp() == nil {
+			// pre uninstall actions are fired only for objects which are about to be deleted
+			preDone, err := action.FireAllContextPreUninstall(newCallbackContext(config, &u), preUninstallFuncs, u)
+			if err != nil {
+				return false, err
+			}
+
+			if !preDone {
+				// wait for pre uninstall actions before deleting the object
+				done = false
+				result.Kept = append(result.Kept, obj)
+				continue
+			}
+		}
+
+		state, err := deleteObject(config, opts, &u, live, &obj)
+		if err != nil {
+			done = false
+			obj.Error = err.Error()
+			result.Failed = append(result.Failed, obj)
+			failed = append(failed, obj.Error)
+			continue
+		}
+
+		switch state {
+		case objectDeleted:
+			done = false
+			result.Deleted = append(result.Deleted, obj)
+		case objectPending:
+			done = false
+			result.Pending = append(result.Pending, obj)
+		case objectStuck:
+			done = false
+			result.Pending = append(result.Pending, obj)
+			result.Stuck = append(result.Stuck, newStuckObject(obj))
+		case objectFinalizersRemoved:
+			result.FinalizersRemoved = append(result.FinalizersRemoved, obj)
+		default:
+			result.NotFound = append(result.NotFound, obj)
+		}
+	}
+
+	if len(failed) > 0 {
+		return false, fmt.Errorf("could not uninstall objects: %s", strings.Join(failed, "; "))
+	}
+
+	return done, nil
+}
+
+type objectState int
+
+const (
+	objectNotFound objectState = iota
+	objectDeleted
+	objectPending
+	objectStuck
+	objectFinalizersRemoved
+)
+
+// getLiveObject returns the object from the cluster or nil if it doesn't exist
+func getLiveObject(config *Config, u *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(u.GroupVersionKind())
+	err := config.Cluster.Client.Get(config.Ctx, client.ObjectKeyFromObject(u), live)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get object %s/%s: %s", u.GetNamespace(), u.GetName(), err.Error())
+	}
+
+	return live, nil
+}
+
+// deleteObject requests deletion of the live object unless it's already being deleted
+// finalizers of objects stuck longer than the FinalizerTimeout are removed if they match the ForceRemoveFinalizers
+// obj is updated with the finalizers and the deletion timestamp of the object pending deletion
+func deleteObject(config *Config, opts *UninstallOpts, u, live *unstructured.Unstructured, obj *UninstallObject) (objectState, error) {
+	if live.GetDeletionTimestamp() == nil {
+		notFound, err := resource.Delete(config.Ctx, config.Cluster.Client, config.Log, *u)
+		if err != nil {
+			return objectNotFound, err
+		}
+		if notFound {
+			// object has been removed in the meantime
+			return objectNotFound, nil
+		}
+		return objectDeleted, nil
+	}
+
+	obj.Finalizers = live.GetFinalizers()
+	obj.DeletionTimestamp = live.GetDeletionTimestamp()
+	if !isStuck(opts, live) {
+		return objectPending, nil
+	}
+
+	if opts.ForceRemoveFinalizers != nil && opts.ForceRemoveFinalizers(*live) {
+		// object pending deletion is removed by the API server right after its finalizers are removed
+		return objectFinalizersRemoved, removeFinalizers(config, live)
+	}
+
+	return objectStuck, nil
 }
 
 func deleteObjects(config *Config, objs []unstructured.Unstructured) (bool, error) {
@@ -82,12 +401,13 @@ func deleteObjects(config *Config, objs []unstructured.Unstructured) (bool, erro
 	return done, nil
 }
 
-func firePostUninstallForObjs(opts *UninstallOpts, objs []unstructured.Unstructured) (bool, error) {
+func firePostUninstallForObjs(config *Config, opts *UninstallOpts, objs []unstructured.Unstructured) (bool, error) {
+	postActions := action.ContextPostUninstalls(opts.PostActions, opts.ContextPostActions)
 	done := true
 	for i := range objs {
 		u := objs[i]
 
-		objDone, err := action.FireAllPostUninstall(opts.PostActions, u)
+		objDone, err := action.FireAllContextPostUninstall(newCallbackContext(config, &u), postActions, u)
 		if err != nil {
 			return false, err
 		}
@@ -2,14 +2,17 @@ package chart
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/kyma-project/manager-toolkit/installation/base/resource"
+	"github.com/kyma-project/manager-toolkit/installation/chart/action"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -128,3 +131,218 @@ func Test_Uninstall(t *testing.T) {
 		})
 	}
 }
+
+func Test_Uninstall_preActions(t *testing.T) {
+	testManifestKey := types.NamespacedName{
+		Name: "test", Namespace: "testnamespace",
+	}
+	cache := NewInMemoryManifestCache()
+	_ = cache.Set(context.Background(), testManifestKey,
+		ContextManifest{Manifest: fmt.Sprint(testServiceAccount, separator, testDeploy)})
+
+	c := fake.NewClientBuilder().WithObjects(testDeployCR.DeepCopy()).Build()
+	config := &Config{
+		Ctx:      context.Background(),
+		Log:      zap.NewNop().Sugar(),
+		Cache:    cache,
+		CacheKey: testManifestKey,
+		Cluster: Cluster{
+			Client: c,
+		},
+	}
+
+	backupDone := false
+	firedFor := []string{}
+	opts := &UninstallOpts{
+		PreActions: []action.PreUninstall{
+			func(u unstructured.Unstructured) (bool, error) {
+				firedFor = append(firedFor, u.GetKind())
+				return backupDone, nil
+			},
+		},
+	}
+
+	done, err := Uninstall(config, opts)
+	require.NoError(t, err)
+	require.False(t, done)
+
+	// deployment is kept until pre uninstall action is done
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-deploy", Namespace: "default"}, testDeployCR.DeepCopy()))
+
+	backupDone = true
+	done, err = Uninstall(config, opts)
+	require.NoError(t, err)
+	require.False(t, done)
+
+	done, err = Uninstall(config, opts)
+	require.NoError(t, err)
+	require.True(t, done)
+
+	// actions are not fired for objects which don't exist anymore
+	require.Equal(t, []string{"Deployment", "Deployment"}, firedFor)
+}
+
+func Test_splitIntoStages(t *testing.T) {
+	objs, err := parseManifest(fmt.Sprint(testCRD, separator, testServiceAccount, separator, testDeploy))
+	require.NoError(t, err)
+
+	t.Run("default stages", func(t *testing.T) {
+		stages := uninstallStages(&UninstallOpts{})
+		split := splitIntoStages(objs, stages)
+
+		require.Len(t, split, 2)
+		require.Equal(t, []string{"Deployment", "ServiceAccount"}, kindsOf(split[0]))
+		require.Equal(t, []string{"CustomResourceDefinition"}, kindsOf(split[1]))
+	})
+
+	t.Run("uninstall first and custom stages", func(t *testing.T) {
+		stages := uninstallStages(&UninstallOpts{
+			UninstallFirst: resource.IsDeployment,
+			Stages: []UninstallStage{
+				{Name: "crds", Predicate: resource.IsCRD},
+				{Name: "deployments", Predicate: resource.IsDeployment},
+			},
+		})
+		split := splitIntoStages(objs, stages)
+
+		require.Equal(t, []string{"first", "crds", "deployments", "remaining"}, stageNames(stages))
+		require.Equal(t, []string{"Deployment"}, kindsOf(split[0]))
+		require.Equal(t, []string{"CustomResourceDefinition"}, kindsOf(split[1]))
+		require.Empty(t, split[2])
+		require.Equal(t, []string{"ServiceAccount"}, kindsOf(split[3]))
+	})
+}
+
+func Test_Uninstall_stages(t *testing.T) {
+	testManifestKey := types.NamespacedName{
+		Name: "test", Namespace: "testnamespace",
+	}
+	cache := NewInMemoryManifestCache()
+	_ = cache.Set(context.Background(), testManifestKey,
+		ContextManifest{Manifest: fmt.Sprint(testCRD, separator, testDeploy)})
+
+	deploy := testDeployCR.DeepCopy()
+	deploy.Finalizers = []string{"test/finalizer"}
+	c := fake.NewClientBuilder().WithObjects(deploy, testCRDObj.DeepCopy()).Build()
+	config := &Config{
+		Ctx:      context.Background(),
+		Log:      zap.NewNop().Sugar(),
+		Cache:    cache,
+		CacheKey: testManifestKey,
+		Cluster: Cluster{
+			Client: c,
+		},
+	}
+
+	done, err := Uninstall(config, &UninstallOpts{})
+	require.NoError(t, err)
+	require.False(t, done)
+
+	// CRD is kept until the deployment is removed
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-crd"}, testCRDObj.DeepCopy()))
+
+	live := testDeployCR.DeepCopy()
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-deploy", Namespace: "default"}, live))
+	live.Finalizers = nil
+	require.NoError(t, c.Update(context.Background(), live))
+
+	done, err = Uninstall(config, &UninstallOpts{})
+	require.NoError(t, err)
+	require.False(t, done)
+
+	done, err = Uninstall(config, &UninstallOpts{})
+	require.NoError(t, err)
+	require.True(t, done)
+}
+
+func Test_Uninstall_result(t *testing.T) {
+	testManifestKey := types.NamespacedName{
+		Name: "test", Namespace: "testnamespace",
+	}
+	cache := NewInMemoryManifestCache()
+	_ = cache.Set(context.Background(), testManifestKey,
+		ContextManifest{Manifest: fmt.Sprint(testCRD, separator, testServiceAccount, separator, testDeploy)})
+
+	deploy := testDeployCR.DeepCopy()
+	deploy.Finalizers = []string{"test/finalizer"}
+	c := fake.NewClientBuilder().WithObjects(deploy, testCRDObj.DeepCopy()).Build()
+	config := &Config{
+		Ctx:      context.Background(),
+		Log:      zap.NewNop().Sugar(),
+		Cache:    cache,
+		CacheKey: testManifestKey,
+		Cluster: Cluster{
+			Client: c,
+		},
+	}
+
+	t.Run("request deletion", func(t *testing.T) {
+		result, err := UninstallWithResult(config, &UninstallOpts{})
+		require.NoError(t, err)
+		require.False(t, result.Done)
+		require.Equal(t, "resources", result.Stage)
+		require.Equal(t, []string{"Deployment"}, uninstallKinds(result.Deleted))
+		require.Equal(t, []string{"ServiceAccount"}, uninstallKinds(result.NotFound))
+		require.Empty(t, result.Pending)
+		require.Equal(t, "waiting for stage 'resources': 1 Deployment to be deleted", result.Summary())
+	})
+
+	t.Run("wait for pending objects", func(t *testing.T) {
+		result, err := UninstallWithResult(config, &UninstallOpts{})
+		require.NoError(t, err)
+		require.False(t, result.Done)
+		require.Empty(t, result.Deleted)
+		require.Len(t, result.Pending, 1)
+		require.Equal(t, "test-deploy", result.Pending[0].Name)
+		require.Equal(t, []string{"test/finalizer"}, result.Pending[0].Finalizers)
+		require.NotNil(t, result.Pending[0].DeletionTimestamp)
+	})
+
+	t.Run("don't fire pre actions for objects pending deletion", func(t *testing.T) {
+		result, err := UninstallWithResult(config, &UninstallOpts{
+			PreActions: []action.PreUninstall{func(u unstructured.Unstructured) (bool, error) {
+				return false, errors.New("test error")
+			}},
+		})
+		require.NoError(t, err)
+		require.False(t, result.Done)
+		require.Empty(t, result.Kept)
+		require.Equal(t, []string{"Deployment"}, uninstallKinds(result.Pending))
+		require.Equal(t, []string{"ServiceAccount"}, uninstallKinds(result.NotFound))
+	})
+
+	t.Run("uninstall remaining objects", func(t *testing.T) {
+		live := testDeployCR.DeepCopy()
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "test-deploy", Namespace: "default"}, live))
+		live.Finalizers = nil
+		require.NoError(t, c.Update(context.Background(), live))
+
+		result, err := UninstallWithResult(config, &UninstallOpts{})
+		require.NoError(t, err)
+		require.Equal(t, "remaining", result.Stage)
+		require.Equal(t, []string{"Deployment", "ServiceAccount"}, uninstallKinds(result.NotFound))
+		require.Equal(t, []string{"CustomResourceDefinition"}, uninstallKinds(result.Deleted))
+
+		result, err = UninstallWithResult(config, &UninstallOpts{})
+		require.NoError(t, err)
+		require.True(t, result.Done)
+		require.Empty(t, result.Stage)
+		require.Equal(t, "uninstalled", result.Summary())
+	})
+}
+
+func uninstallKinds(objs []UninstallObject) []string {
+	kinds := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		kinds = append(kinds, obj.Kind)
+	}
+	return kinds
+}
+
+func stageNames(stages []UninstallStage) []string {
+	names := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		names = append(names, stage.Name)
+	}
+	return names
+}
@@ -0,0 +1,125 @@
+package chart
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ValuesSource provides chart values merged before the CustomFlags.
+// It can use the cluster client to read values stored on the cluster.
+type ValuesSource func(ctx context.Context, cluster Cluster) (map[string]interface{}, error)
+
+// ValuesFromFile reads values from the YAML file
+func ValuesFromFile(path string) ValuesSource {
+	return func(_ context.Context, _ Cluster) (map[string]interface{}, error) {
+		values, err := chartutil.ReadValuesFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("while reading values file '%s': %s", path, err.Error())
+		}
+
+		return values, nil
+	}
+}
+
+// ValuesFromMap returns the given values
+func ValuesFromMap(values map[string]interface{}) ValuesSource {
+	return func(_ context.Context, _ Cluster) (map[string]interface{}, error) {
+		return values, nil
+	}
+}
+
+// ValuesFromConfigMap reads YAML values stored under the dataKey of the ConfigMap
+func ValuesFromConfigMap(key types.NamespacedName, dataKey string) ValuesSource {
+	return func(ctx context.Context, cluster Cluster) (map[string]interface{}, error) {
+		configMap := corev1.ConfigMap{}
+		if cluster.Client == nil {
+			return nil, fmt.Errorf("cluster client is required to get values configmap %s", key.String())
+		}
+
+		err := cluster.Client.Get(ctx, key, &configMap)
+		if err != nil {
+			return nil, fmt.Errorf("while getting values configmap %s: %s", key.String(), err.Error())
+		}
+
+		return readValues(configMap.Data[dataKey], dataKey, key)
+	}
+}
+
+// ValuesFromSecret reads YAML values stored under the dataKey of the Secret
+func ValuesFromSecret(key types.NamespacedName, dataKey string) ValuesSource {
+	return func(ctx context.Context, cluster Cluster) (map[string]interface{}, error) {
+		secret := corev1.Secret{}
+		if cluster.Client == nil {
+			return nil, fmt.Errorf("cluster client is required to get values secret %s", key.String())
+		}
+
+		err := cluster.Client.Get(ctx, key, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("while getting values secret %s: %s", key.String(), err.Error())
+		}
+
+		return readValues(string(secret.Data[dataKey]), dataKey, key)
+	}
+}
+
+func readValues(data string, dataKey string, key types.NamespacedName) (map[string]interface{}, error) {
+	values, err := chartutil.ReadValues([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("while reading values from %s key of %s: %s", dataKey, key.String(), err.Error())
+	}
+
+	return values, nil
+}
+
+// mergeValues merges values from all sources, following ones override previous ones and customFlags override all of them.
+// Values are coalesced the same way as Helm does it, so null removes the key set by the previous source.
+func mergeValues(ctx context.Context, cluster Cluster, sources []ValuesSource, customFlags map[string]interface{}) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for _, source := range sources {
+		sourceValues, err := source(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		values = coalesceValues(sourceValues, values)
+	}
+
+	return coalesceValues(customFlags, values), nil
+}
+
+// coalesceValues returns copy of values with missing keys taken from the base
+func coalesceValues(values, base map[string]interface{}) map[string]interface{} {
+	// CoalesceTables modifies the first table and uses nested maps from the second one
+	dst, _ := deepCopyValue(values).(map[string]interface{})
+	src, _ := deepCopyValue(base).(map[string]interface{})
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+
+	return chartutil.CoalesceTables(dst, src)
+}
+
+// equalValues compares values by their JSON representation because numbers change their types
+// after being stored in the cache
+func equalValues(values, otherValues map[string]interface{}) bool {
+	if len(values) == 0 && len(otherValues) == 0 {
+		return true
+	}
+
+	valuesJSON, err := json.Marshal(values)
+	if err != nil {
+		return false
+	}
+
+	otherValuesJSON, err := json.Marshal(otherValues)
+	if err != nil {
+		return false
+	}
+
+	return string(valuesJSON) == string(otherValuesJSON)
+}
@@ -0,0 +1,120 @@
+package chart
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_mergeValues(t *testing.T) {
+	ctx := context.Background()
+	configMapKey := types.NamespacedName{Name: "values", Namespace: "kyma-system"}
+	secretKey := types.NamespacedName{Name: "secret-values", Namespace: "kyma-system"}
+
+	valuesPath := filepath.Join(t.TempDir(), "values.yaml")
+	require.NoError(t, os.WriteFile(valuesPath, []byte("replicas: 1\nimage:\n  registry: file.io\n  tag: v1\n"), 0o600))
+
+	cluster := Cluster{
+		Client: fake.NewClientBuilder().WithObjects(
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: configMapKey.Name, Namespace: configMapKey.Namespace},
+				Data:       map[string]string{"values.yaml": "image:\n  tag: v2\n"},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretKey.Name, Namespace: secretKey.Namespace},
+				Data:       map[string][]byte{"values.yaml": []byte("password: secret\n")},
+			},
+		).Build(),
+	}
+
+	t.Run("merge values in order", func(t *testing.T) {
+		values, err := mergeValues(ctx, cluster, []ValuesSource{
+			ValuesFromFile(valuesPath),
+			ValuesFromConfigMap(configMapKey, "values.yaml"),
+			ValuesFromSecret(secretKey, "values.yaml"),
+			ValuesFromMap(map[string]interface{}{"replicas": 3}),
+		}, map[string]interface{}{
+			"image": map[string]interface{}{"registry": "flags.io"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"replicas": 3,
+			"password": "secret",
+			"image": map[string]interface{}{
+				"registry": "flags.io",
+				"tag":      "v2",
+			},
+		}, values)
+	})
+
+	t.Run("remove values with null", func(t *testing.T) {
+		values, err := mergeValues(ctx, cluster, []ValuesSource{
+			ValuesFromFile(valuesPath),
+			ValuesFromMap(map[string]interface{}{"image": nil}),
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"replicas": float64(1)}, values)
+	})
+
+	t.Run("do not modify sources", func(t *testing.T) {
+		overlay := map[string]interface{}{"image": map[string]interface{}{"tag": "v3"}}
+		flags := map[string]interface{}{"image": map[string]interface{}{"registry": "flags.io"}}
+
+		_, err := mergeValues(ctx, cluster, []ValuesSource{ValuesFromMap(overlay)}, flags)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"image": map[string]interface{}{"tag": "v3"}}, overlay)
+		require.Equal(t, map[string]interface{}{"image": map[string]interface{}{"registry": "flags.io"}}, flags)
+	})
+
+	t.Run("file not found", func(t *testing.T) {
+		_, err := mergeValues(ctx, cluster, []ValuesSource{
+			ValuesFromFile(filepath.Join(t.TempDir(), "not-existing.yaml")),
+		}, nil)
+		require.ErrorContains(t, err, "while reading values file")
+	})
+
+	t.Run("configmap not found", func(t *testing.T) {
+		_, err := mergeValues(ctx, cluster, []ValuesSource{
+			ValuesFromConfigMap(types.NamespacedName{Name: "other", Namespace: "kyma-system"}, "values.yaml"),
+		}, nil)
+		require.ErrorContains(t, err, "while getting values configmap kyma-system/other")
+	})
+
+	t.Run("wrong values format", func(t *testing.T) {
+		_, err := mergeValues(ctx, cluster, []ValuesSource{
+			ValuesFromSecret(secretKey, "values.yaml"),
+			ValuesFromConfigMap(configMapKey, "values.yaml"),
+			func(_ context.Context, _ Cluster) (map[string]interface{}, error) {
+				return readValues("- not\n- a map", "values.yaml", configMapKey)
+			},
+		}, nil)
+		require.ErrorContains(t, err, "while reading values from values.yaml key of kyma-system/values")
+	})
+}
+
+func Test_equalValues(t *testing.T) {
+	t.Run("empty values", func(t *testing.T) {
+		require.True(t, equalValues(nil, map[string]interface{}{}))
+	})
+
+	t.Run("numbers of different types", func(t *testing.T) {
+		require.True(t, equalValues(
+			map[string]interface{}{"replicas": 1},
+			map[string]interface{}{"replicas": float64(1)},
+		))
+	})
+
+	t.Run("different values", func(t *testing.T) {
+		require.False(t, equalValues(
+			map[string]interface{}{"replicas": 1},
+			map[string]interface{}{"replicas": 2},
+		))
+	})
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/kyma-project/manager-toolkit/installation/base/resource"
 
+	"go.opentelemetry.io/otel/attribute"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
@@ -31,6 +32,18 @@ type VerificationResult struct {
 // If an error occurs during the verification process, it returns an error.
 // It returns a VerificationResult indicating readiness and any relevant reason.
 func Verify(config *Config) (*VerificationResult, error) {
+	spanConfig, span := startSpan(config, "chart.verify", releaseAttributes(config)...)
+	result, err := verify(spanConfig)
+	if result != nil {
+		span.SetAttributes(attribute.Bool("chart.ready", result.Ready), attribute.String("chart.reason", result.Reason))
+	}
+	endSpan(span, err)
+	observeVerify(config, result)
+	recordVerifyEvent(config, result, err)
+	return result, err
+}
+
+func verify(config *Config) (*VerificationResult, error) {
 	spec, err := config.Cache.Get(config.Ctx, config.CacheKey)
 	if err != nil {
 		return nil, fmt.Errorf("could not render manifest from chart: %s", err.Error())